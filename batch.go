@@ -0,0 +1,308 @@
+package dcache
+
+import (
+	"dcache/cachepb"
+	"dcache/singleflight"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// BatchGetter 可以一次获取多个key的源数据
+// 返回结果中不存在的key视为 ErrNotFound
+type BatchGetter interface {
+	GetMany(keys []string) (map[string][]byte, error)
+}
+
+// BatchGetterFunc 同时实现了 Getter 和 BatchGetter
+type BatchGetterFunc func(keys []string) (map[string][]byte, error)
+
+func (f BatchGetterFunc) Get(key string) ([]byte, error) {
+	values, err := f([]string{key})
+	if err != nil {
+		return nil, err
+	}
+	if b, ok := values[key]; ok {
+		return b, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (f BatchGetterFunc) GetMany(keys []string) (map[string][]byte, error) {
+	return f(keys)
+}
+
+// GetMany 批量获取数据
+// 本地命中的key直接返回，其余的key按所属节点分组，每个节点只发起一次批量请求，
+// 属于本机的key通过 BatchGetter（如果 Getter 实现了）一次性从源数据加载。
+// 返回所有获取成功的key，不存在的key不在结果中；
+// 如果有key获取失败（不包括不存在），同时返回第一个错误
+func (g *Group) GetMany(keys []string) (map[string]ByteView, error) {
+	entries, errs := g.getMany(keys)
+	values := make(map[string]ByteView, len(entries))
+	for key, e := range entries {
+		value, err := e.decode()
+		if err != nil {
+			errs[key] = err
+			continue
+		}
+		values[key] = value
+	}
+	for _, key := range keys {
+		if err, ok := errs[key]; ok && !IsNotFound(err) {
+			return values, err
+		}
+	}
+	return values, nil
+}
+
+// getMany 批量获取数据，返回获取成功的记录以及每个失败的key对应的错误
+func (g *Group) getMany(keys []string) (map[string]entry, map[string]error) {
+	values := make(map[string]entry, len(keys))
+	errs := make(map[string]error)
+	var mu sync.Mutex // 保护 values 和 errs
+	var local []string
+	remote := make(map[PeerGetter][]string)
+
+	gen := g.Generation()
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if key == "" {
+			errs[key] = errors.New("key is required ")
+			continue
+		}
+		if e, ok := g.mainCache.getGen(key, gen); ok && g.serveCached(key, e) {
+			g.Stats.CacheHits.Add(1)
+			values[key] = e
+			continue
+		}
+		if g.isNegative(key) {
+			errs[key] = ErrNotFound
+			continue
+		}
+		if e, ok := g.getFromDisk(key); ok {
+			values[key] = e
+			continue
+		}
+		if pickers := g.peerPicker(); pickers != nil {
+			if peer, ok := pickers.PickPeer(genKey(key, gen)); ok {
+				remote[peer] = append(remote[peer], key)
+				continue
+			}
+		}
+		local = append(local, key)
+	}
+
+	// 每个节点并发发起一次批量请求
+	var wg sync.WaitGroup
+	for peer, peerKeys := range remote {
+		wg.Add(1)
+		go func(peer PeerGetter, peerKeys []string) {
+			defer wg.Done()
+			got, gotErrs, err := g.getManyFromPeer(peer, peerKeys)
+			if err != nil {
+				log.Println("[cache] Failed to batch get from peer", err)
+			}
+			var failed []string
+			mu.Lock()
+			for _, key := range peerKeys {
+				if value, ok := got[key]; ok {
+					values[key] = value
+				} else if err, ok := gotErrs[key]; ok {
+					// 远程节点已经从源数据加载过，不再从本地加载
+					if IsNotFound(err) {
+						g.addNegative(key)
+					}
+					errs[key] = err
+				} else {
+					failed = append(failed, key)
+				}
+			}
+			mu.Unlock()
+			// 远程节点没有返回的key，从本地加载
+			if len(failed) > 0 {
+				g.getManyLocally(failed, values, errs, &mu)
+			}
+		}(peer, peerKeys)
+	}
+	if len(local) > 0 {
+		g.getManyLocally(local, values, errs, &mu)
+	}
+	wg.Wait()
+	return values, errs
+}
+
+// getManyFromPeer 从远程节点批量获取数据，节点不支持批量请求时逐个获取，
+// 返回获取成功的记录，以及远程节点确认不存在或者获取失败的key 对应的错误
+func (g *Group) getManyFromPeer(peer PeerGetter, keys []string) (map[string]entry, map[string]error, error) {
+	values := make(map[string]entry, len(keys))
+	errs := make(map[string]error)
+	batch, ok := peer.(BatchPeerGetter)
+	if !ok {
+		var firstErr error
+		for _, key := range keys {
+			e, err := g.getFromPeer(peer, key)
+			switch {
+			case err == nil:
+				values[key] = e
+			case IsNotFound(err):
+				errs[key] = err
+			case firstErr == nil:
+				firstErr = err
+			}
+		}
+		return values, errs, firstErr
+	}
+
+	resp := &cachepb.BatchResponse{}
+	err := batch.BatchGet(&cachepb.BatchRequest{
+		Group:      g.name,
+		Keys:       keys,
+		Generation: g.Generation(),
+	}, resp)
+	if err != nil {
+		return nil, nil, err
+	}
+	for key, r := range resp.Values {
+		e, err := responseEntry(r)
+		if err != nil {
+			errs[key] = err
+			continue
+		}
+		// 校验失败的key不在结果中，之后从源数据重新加载
+		if g.verifyFromPeer(key, e) == nil {
+			values[key] = e
+		}
+	}
+	return values, errs, nil
+}
+
+// getManyLocally 从源数据批量加载，结果写入 values 和 errs
+// 与 load 使用同一个 singleflight，正在被其他请求加载的key 等待该请求的结果
+func (g *Group) getManyLocally(keys []string, values map[string]entry, errs map[string]error, mu *sync.Mutex) {
+	gen := g.Generation()
+	loaderKeys := make([]string, 0, len(keys))
+	keyOf := make(map[string]string, len(keys))
+	for _, key := range keys {
+		k := genKey(key, gen)
+		loaderKeys = append(loaderKeys, k)
+		keyOf[k] = key
+	}
+	results := g.loader.DoMany(loaderKeys, func(loaderKeys []string) map[string]singleflight.Result {
+		own := make([]string, 0, len(loaderKeys))
+		for _, k := range loaderKeys {
+			own = append(own, keyOf[k])
+		}
+		got, gotErrs := g.loadMany(own, gen)
+		results := make(map[string]singleflight.Result, len(loaderKeys))
+		for _, k := range loaderKeys {
+			if e, ok := got[keyOf[k]]; ok {
+				results[k] = singleflight.Result{Val: e}
+			} else {
+				results[k] = singleflight.Result{Err: gotErrs[keyOf[k]]}
+			}
+		}
+		return results
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	for k, r := range results {
+		if r.Err != nil {
+			errs[keyOf[k]] = r.Err
+		} else {
+			values[keyOf[k]] = r.Val.(entry)
+		}
+	}
+}
+
+// loadMany 从源数据批量加载 keys，返回加载成功的记录以及每个失败的key 对应的错误
+func (g *Group) loadMany(keys []string, gen uint64) (map[string]entry, map[string]error) {
+	values := make(map[string]entry, len(keys))
+	errs := make(map[string]error)
+	// 还没有写入后端存储的值不从源数据加载
+	if g.writer != nil {
+		var rest []string
+		for _, key := range keys {
+			lease := g.leases.acquire(key)
+			if p, ok := g.writer.lookup(key); ok {
+				values[key] = g.populate(key, p.value, p.tags, 0, gen, lease)
+			} else {
+				g.leases.commit(key, lease, func() {})
+				rest = append(rest, key)
+			}
+		}
+		if keys = rest; len(keys) == 0 {
+			return values, errs
+		}
+	}
+	var getMany func(keys []string) (map[string][]byte, map[string][]string, error)
+	if batch, ok := g.getter.(BatchTagGetter); ok {
+		getMany = batch.GetManyWithTags
+	} else if batch, ok := g.getter.(BatchGetter); ok {
+		// BatchGetter 无法返回标签，TagGetter 逐个加载
+		if _, tagged := g.getter.(TagGetter); !tagged {
+			getMany = func(keys []string) (map[string][]byte, map[string][]string, error) {
+				values, err := batch.GetMany(keys)
+				return values, nil, err
+			}
+		}
+	}
+	if getMany == nil {
+		// getLocally 获取并释放租约
+		for _, key := range keys {
+			if e, err := g.getLocally(key); err != nil {
+				errs[key] = err
+			} else {
+				values[key] = e
+			}
+		}
+		return values, errs
+	}
+
+	// 每个key 都会释放租约
+	leases := make(map[string]uint64, len(keys))
+	for _, key := range keys {
+		leases[key] = g.leases.acquire(key)
+	}
+	start := time.Now()
+	got, tags, err := getMany(keys)
+	cost := time.Since(start) / time.Duration(len(keys))
+	for _, key := range keys {
+		if err != nil {
+			g.leases.commit(key, leases[key], func() {})
+			errs[key] = err
+			continue
+		}
+		b, ok := got[key]
+		if !ok {
+			g.leases.commit(key, leases[key], func() { g.addNegative(key) })
+			errs[key] = ErrNotFound
+			continue
+		}
+		values[key] = g.populate(key, b, tags[key], cost, gen, leases[key])
+	}
+	return values, errs
+}
+
+// batchResponse 处理远程节点的批量请求，获取失败的key 在返回结果中设置 Error
+func (g *Group) batchResponse(keys []string) *cachepb.BatchResponse {
+	values, errs := g.getMany(keys)
+	resp := &cachepb.BatchResponse{Values: make(map[string]*cachepb.Response, len(keys))}
+	for key, e := range values {
+		resp.Values[key] = entryResponse(e)
+	}
+	for key, err := range errs {
+		if IsNotFound(err) {
+			resp.Values[key] = &cachepb.Response{NotFound: true}
+		} else {
+			resp.Values[key] = &cachepb.Response{Error: err.Error()}
+		}
+	}
+	return resp
+}
@@ -0,0 +1,199 @@
+package dcache
+
+import (
+	"dcache/cachepb"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeBatchPeer struct {
+	fakePeer
+	batches [][]string
+}
+
+func (p *fakeBatchPeer) BatchGet(in *cachepb.BatchRequest, out *cachepb.BatchResponse) error {
+	p.batches = append(p.batches, in.Keys)
+	out.Values = make(map[string]*cachepb.Response)
+	for _, key := range in.Keys {
+		if key == "remote-missing" {
+			out.Values[key] = &cachepb.Response{NotFound: true}
+			continue
+		}
+		if key == "remote-failed" {
+			out.Values[key] = &cachepb.Response{Error: "origin unavailable"}
+			continue
+		}
+		value := []byte("peer-" + key)
+		out.Values[key] = &cachepb.Response{Value: value, Checksum: checksum(ByteView{b: value})}
+	}
+	return nil
+}
+
+// keyPicker 以 remote 开头的key属于远程节点
+type keyPicker struct {
+	peer PeerGetter
+}
+
+func (p *keyPicker) PickPeer(key string) (PeerGetter, bool) {
+	if len(key) >= 6 && key[:6] == "remote" {
+		return p.peer, true
+	}
+	return nil, false
+}
+
+func TestGroup_GetMany(t *testing.T) {
+	var batches [][]string
+	gc := NewGroup("get-many", 2<<10, BatchGetterFunc(func(keys []string) (map[string][]byte, error) {
+		batches = append(batches, keys)
+		values := make(map[string][]byte)
+		for _, key := range keys {
+			if v, ok := db[key]; ok {
+				values[key] = []byte(v)
+			}
+		}
+		return values, nil
+	}))
+	peer := &fakeBatchPeer{}
+	gc.RegisterPeers(&keyPicker{peer: peer})
+
+	gc.Get("Tom") // Tom 已经在本地缓存中
+	batches = nil
+
+	values, err := gc.GetMany([]string{"Tom", "Jack", "Sam", "Nobody", "remote1", "remote2", "remote-missing", "Jack"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	for k, v := range values {
+		got[k] = v.String()
+	}
+	want := map[string]string{
+		"Tom":     "630",
+		"Jack":    "589",
+		"Sam":     "567",
+		"remote1": "peer-remote1",
+		"remote2": "peer-remote2",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("GetMany = %v, want %v", got, want)
+	}
+	if len(batches) != 1 || len(batches[0]) != 3 {
+		t.Fatalf("origin batches = %v, want one batch of 3 keys", batches)
+	}
+	if len(peer.batches) != 1 || len(peer.batches[0]) != 3 {
+		t.Fatalf("peer batches = %v, want one batch of 3 keys", peer.batches)
+	}
+}
+
+func TestGroup_GetManyErrors(t *testing.T) {
+	var loaded []string
+	gc := NewRegistry().NewGroup("get-many-errors", 2<<10, BatchGetterFunc(func(keys []string) (map[string][]byte, error) {
+		loaded = append(loaded, keys...)
+		if keys[0] == "failed" {
+			return nil, errors.New("origin unavailable")
+		}
+		return map[string][]byte{}, nil
+	}))
+	gc.RegisterPeers(&keyPicker{peer: &fakeBatchPeer{}})
+
+	// 远程节点获取失败的key 返回错误，不再从本地加载
+	values, err := gc.GetMany([]string{"remote1", "remote-failed"})
+	if err == nil || !strings.Contains(err.Error(), "origin unavailable") || len(values) != 1 {
+		t.Fatalf("values = %v, err = %v", values, err)
+	}
+	if len(loaded) != 0 {
+		t.Fatalf("loaded from origin: %v", loaded)
+	}
+
+	// 本机获取失败的key 在批量响应中设置 Error
+	resp := gc.batchResponse([]string{"failed"})
+	if r := resp.Values["failed"]; r == nil || r.Error == "" {
+		t.Fatalf("resp = %v", resp)
+	}
+}
+
+func TestGroup_GetManyDedup(t *testing.T) {
+	// 批量加载期间同一个key 的 Get 等待批量加载的结果
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	var mu sync.Mutex
+	loads := 0
+	gc := NewRegistry().NewGroup("get-many-dedup", 2<<10, BatchGetterFunc(func(keys []string) (map[string][]byte, error) {
+		mu.Lock()
+		loads++
+		mu.Unlock()
+		started <- struct{}{}
+		<-release
+		return map[string][]byte{"k": []byte("v")}, nil
+	}))
+	done := make(chan ByteView)
+	go func() {
+		values, _ := gc.GetMany([]string{"k"})
+		done <- values["k"]
+	}()
+	<-started
+	go func() {
+		v, _ := gc.Get("k")
+		done <- v
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	for i := 0; i < 2; i++ {
+		if v := <-done; v.String() != "v" {
+			t.Fatalf("v = %q", v)
+		}
+	}
+	if loads != 1 || gc.Stats.StaleSets.Get() != 0 {
+		t.Fatalf("loads = %d, stale sets = %d", loads, gc.Stats.StaleSets.Get())
+	}
+	if _, ok := gc.mainCache.get("k"); !ok {
+		t.Fatal("k should be cached")
+	}
+
+	// 代增加后逐个加载的key 同样与 Get 合并
+	s := newSlowGetter()
+	gc = NewRegistry().NewGroup("get-many-dedup-gen", 2<<10, s)
+	gc.BumpGeneration()
+	go func() {
+		values, _ := gc.GetMany([]string{"k"})
+		done <- values["k"]
+	}()
+	loadDuring(t, gc, s, "k", func() {
+		time.Sleep(10 * time.Millisecond)
+	})
+	if v := <-done; v.String() != "old" || len(s.started) != 0 {
+		t.Fatalf("v = %q, key should be loaded once", v)
+	}
+}
+
+func TestHTTPPool_BatchGet(t *testing.T) {
+	NewGroup("batch-http", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		if v, ok := db[key]; ok {
+			return []byte(v), nil
+		}
+		return nil, ErrNotFound
+	}))
+	srv := httptest.NewServer(NewHTTPPool("self"))
+	defer srv.Close()
+
+	getter := &httpGetter{baseURL: srv.URL + defaultBasePath}
+	out := &cachepb.BatchResponse{}
+	err := getter.BatchGet(&cachepb.BatchRequest{Group: "batch-http", Keys: []string{"Tom", "Sam", "Nobody"}}, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for k := range out.Values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if !reflect.DeepEqual(keys, []string{"Nobody", "Sam", "Tom"}) || !out.Values["Nobody"].NotFound ||
+		string(out.Values["Tom"].Value) != "630" {
+		t.Fatalf("unexpected batch response: %v", out)
+	}
+}
@@ -0,0 +1,131 @@
+package dcache
+
+import (
+	"sort"
+	"sync/atomic"
+)
+
+/*
+多个 Group 共享的内存上限：
+
+每个 Group 仍然受自己的 cacheBytes 限制，同时所有 Group 使用的内存之和不超过上限。
+超过上限时，从 "价值密度" 最低的 Group 中淘汰最久未访问的记录：
+
+	density = weight * (recentHits + 1) / bytes
+
+recentHits 为最近命中次数的指数加权平均，空闲的 Group 会逐渐让出内存给繁忙的 Group。
+*/
+
+// hitDecay 每次采样时，之前的命中次数的衰减系数
+const hitDecay = 0.5
+
+// GroupShare 一个 Group 在共享内存中的占比
+type GroupShare struct {
+	Name       string
+	Bytes      int64   // 使用的内存
+	Weight     float64 // 权重
+	RecentHits float64 // 最近的命中次数
+	Share      float64 // 占所有 Group 使用内存的比例
+}
+
+// SetMemoryBudget 设置 Registry 中所有 Group 共享的内存上限，为0时不限制
+func (r *Registry) SetMemoryBudget(maxBytes int64) {
+	atomic.StoreInt64(&r.budget, maxBytes)
+	r.enforceBudget()
+}
+
+// SetMemoryBudget 设置默认的 Registry 中所有 Group 共享的内存上限
+func SetMemoryBudget(maxBytes int64) {
+	DefaultRegistry.SetMemoryBudget(maxBytes)
+}
+
+// MemoryBudget 返回共享的内存上限
+func (r *Registry) MemoryBudget() int64 {
+	return atomic.LoadInt64(&r.budget)
+}
+
+// enforceBudget 超过共享的内存上限时，跨 Group 淘汰记录
+// 每次放入缓存时调用，没有超过上限时只读取原子计数，不加锁
+func (r *Registry) enforceBudget() {
+	budget := atomic.LoadInt64(&r.budget)
+	if budget <= 0 || atomic.LoadInt64(&r.used) <= budget {
+		return
+	}
+	r.budgetMu.Lock()
+	defer r.budgetMu.Unlock()
+
+	groups := r.ListGroups()
+	used := make([]int64, len(groups))
+	var total int64
+	for i, g := range groups {
+		used[i] = g.mainCache.bytes()
+		total += used[i]
+	}
+	if total <= budget {
+		return
+	}
+
+	r.sampleHits(groups)
+	for total > budget {
+		victim := -1
+		var lowest float64
+		for i, g := range groups {
+			if used[i] == 0 {
+				continue
+			}
+			density := g.weight * (g.recentHits + 1) / float64(used[i])
+			if victim < 0 || density < lowest {
+				victim, lowest = i, density
+			}
+		}
+		if victim < 0 {
+			return
+		}
+		g := groups[victim]
+		if !g.mainCache.removeOldest() {
+			used[victim] = 0
+			continue
+		}
+		after := g.mainCache.bytes()
+		total -= used[victim] - after
+		used[victim] = after
+	}
+}
+
+// sampleHits 更新每个 Group 最近的命中次数，调用时需要持有 budgetMu
+func (r *Registry) sampleHits(groups []*Group) {
+	for _, g := range groups {
+		hits := g.Stats.CacheHits.Get()
+		g.recentHits = g.recentHits*hitDecay + float64(hits-g.sampledHits)
+		g.sampledHits = hits
+	}
+}
+
+// BudgetReport 返回每个 Group 使用的内存及其占比，按使用的内存从大到小排序
+func (r *Registry) BudgetReport() []GroupShare {
+	r.budgetMu.Lock()
+	defer r.budgetMu.Unlock()
+
+	groups := r.ListGroups()
+	r.sampleHits(groups)
+	shares := make([]GroupShare, len(groups))
+	var total int64
+	for i, g := range groups {
+		shares[i] = GroupShare{
+			Name:       g.name,
+			Bytes:      g.mainCache.bytes(),
+			Weight:     g.weight,
+			RecentHits: g.recentHits,
+		}
+		total += shares[i].Bytes
+	}
+	for i := range shares {
+		if total > 0 {
+			shares[i].Share = float64(shares[i].Bytes) / float64(total)
+		}
+	}
+	sort.SliceStable(shares, func(i, j int) bool {
+		return shares[i].Bytes > shares[j].Bytes
+	})
+	return shares
+}
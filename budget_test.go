@@ -0,0 +1,81 @@
+package dcache
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRegistry_MemoryBudget(t *testing.T) {
+	r := NewRegistry()
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return []byte("value"), nil
+	})
+	idle := r.NewGroup("idle", 1<<20, getter)
+	busy := r.NewGroup("busy", 1<<20, getter, WithWeight(2))
+
+	// 每条记录 k0..k9 占用的内存
+	size := 2 + 5 + entryOverhead
+	for i := 0; i < 5; i++ {
+		idle.Get(fmt.Sprintf("k%d", i))
+		busy.Get(fmt.Sprintf("k%d", i))
+	}
+	for i := 0; i < 10; i++ {
+		busy.Get("k0")
+	}
+	r.SetMemoryBudget(size * 8)
+
+	report := r.BudgetReport()
+	var total int64
+	for _, share := range report {
+		total += share.Bytes
+	}
+	if total > size*8 {
+		t.Fatalf("total bytes %d over budget", total)
+	}
+	// 空闲且权重低的 Group 让出内存
+	if idle.mainCache.bytes() != size*3 || busy.mainCache.bytes() != size*5 {
+		t.Fatalf("idle = %d, busy = %d", idle.mainCache.bytes(), busy.mainCache.bytes())
+	}
+	if report[0].Name != "busy" || report[0].Weight != 2 || report[0].Share <= 0.5 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	// 新加入的记录同样受上限限制
+	for i := 5; i < 10; i++ {
+		busy.Get(fmt.Sprintf("k%d", i))
+	}
+	if used := idle.mainCache.bytes() + busy.mainCache.bytes(); used > size*8 {
+		t.Fatalf("used %d over budget", used)
+	}
+}
+
+func TestRegistry_MemoryUsed(t *testing.T) {
+	r := NewRegistry()
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return []byte("value"), nil
+	})
+	g1 := r.NewGroup("used-1", 1<<20, getter)
+	g2 := r.NewGroup("used-2", 1<<20, getter)
+	check := func() {
+		t.Helper()
+		if used, want := atomic.LoadInt64(&r.used), g1.mainCache.bytes()+g2.mainCache.bytes(); used != want {
+			t.Fatalf("used = %d, want %d", used, want)
+		}
+	}
+	// 放入、删除、淘汰记录时更新所有 Group 使用的内存之和
+	for i := 0; i < 10; i++ {
+		g1.Get(fmt.Sprintf("k%d", i))
+		g2.Get(fmt.Sprintf("k%d", i))
+	}
+	check()
+	g1.Invalidate("k0")
+	g2.SetCacheBytes(100)
+	check()
+	// 删除的 Group 不再计入
+	r.DeleteGroup("used-2")
+	g2.Get("k1")
+	if used := atomic.LoadInt64(&r.used); used != g1.mainCache.bytes() {
+		t.Fatalf("used = %d after delete", used)
+	}
+}
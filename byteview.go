@@ -1,22 +1,265 @@
 package dcache
 
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+)
+
 type ByteView struct {
-	b []byte // 存储真实的缓存值
+	b      []byte   // 存储真实的缓存值
+	chunks [][]byte // 大的值分块存储，不为 nil 时忽略 b
+	n      int      // 分块存储时的总长度
+}
+
+// chunkedView 创建分块存储的 ByteView，不会拷贝数据
+func chunkedView(chunks [][]byte) ByteView {
+	if len(chunks) == 0 {
+		return ByteView{}
+	}
+	if len(chunks) == 1 {
+		return ByteView{b: chunks[0]}
+	}
+	n := 0
+	for _, c := range chunks {
+		n += len(c)
+	}
+	return ByteView{chunks: chunks, n: n}
+}
+
+// splitChunks 将数据拷贝到多个不超过 size 的块中
+func splitChunks(b []byte, size int) [][]byte {
+	chunks := make([][]byte, 0, (len(b)+size-1)/size)
+	for len(b) > 0 {
+		n := size
+		if n > len(b) {
+			n = len(b)
+		}
+		chunks = append(chunks, cloeBytes(b[:n]))
+		b = b[n:]
+	}
+	return chunks
 }
 
 // Len 实现Value 接口
 func (b ByteView) Len() int {
+	if b.chunks != nil {
+		return b.n
+	}
 	return len(b.b)
 }
 
 // 拷贝原生数据，防止原始数据被修改
 func (b ByteView) ByteSlice() []byte {
-	c := make([]byte, len(b.b))
-	copy(c, b.b)
+	c := make([]byte, b.Len())
+	b.Copy(c)
 	return c
 }
 
 // String 用于测试
 func (b ByteView) String() string {
-	return string(b.b)
+	if b.chunks == nil {
+		return string(b.b)
+	}
+	var sb strings.Builder
+	sb.Grow(b.n)
+	for _, c := range b.chunks {
+		sb.Write(c)
+	}
+	return sb.String()
+}
+
+// bytes 返回连续存储的数据，分块存储时需要拷贝，调用方不能修改返回值
+func (b ByteView) bytes() []byte {
+	if b.chunks == nil {
+		return b.b
+	}
+	return b.ByteSlice()
+}
+
+// eachChunk 依次处理每一块数据
+func (b ByteView) eachChunk(fn func(c []byte) bool) {
+	if b.chunks == nil {
+		fn(b.b)
+		return
+	}
+	for _, c := range b.chunks {
+		if !fn(c) {
+			return
+		}
+	}
+}
+
+// At 返回第 i 个字节
+func (b ByteView) At(i int) byte {
+	if b.chunks == nil {
+		return b.b[i]
+	}
+	for _, c := range b.chunks {
+		if i < len(c) {
+			return c[i]
+		}
+		i -= len(c)
+	}
+	panic("dcache: ByteView index out of range")
+}
+
+// Slice 返回 [from, to) 之间的数据，不会拷贝
+func (b ByteView) Slice(from, to int) ByteView {
+	if b.chunks == nil {
+		return ByteView{b: b.b[from:to]}
+	}
+	if from < 0 || to > b.n || from > to {
+		panic("dcache: ByteView slice bounds out of range")
+	}
+	var chunks [][]byte
+	start := 0
+	for _, c := range b.chunks {
+		end := start + len(c)
+		if end > from && start < to {
+			lo, hi := 0, len(c)
+			if from > start {
+				lo = from - start
+			}
+			if to < end {
+				hi = to - start
+			}
+			chunks = append(chunks, c[lo:hi])
+		}
+		start = end
+	}
+	return chunkedView(chunks)
+}
+
+// SliceFrom 返回从 from 开始的数据，不会拷贝
+func (b ByteView) SliceFrom(from int) ByteView {
+	return b.Slice(from, b.Len())
+}
+
+// Copy 将数据拷贝到 dest 中，返回拷贝的字节数
+func (b ByteView) Copy(dest []byte) int {
+	n := 0
+	b.eachChunk(func(c []byte) bool {
+		n += copy(dest[n:], c)
+		return n < len(dest)
+	})
+	return n
+}
+
+// Equal 判断两个 ByteView 的内容是否相同
+func (b ByteView) Equal(b2 ByteView) bool {
+	if b2.chunks == nil {
+		return b.EqualBytes(b2.b)
+	}
+	if b.Len() != b2.Len() {
+		return false
+	}
+	off, equal := 0, true
+	b2.eachChunk(func(c []byte) bool {
+		equal = b.Slice(off, off+len(c)).EqualBytes(c)
+		off += len(c)
+		return equal
+	})
+	return equal
+}
+
+// EqualString 判断内容是否与 s 相同
+func (b ByteView) EqualString(s string) bool {
+	if b.Len() != len(s) {
+		return false
+	}
+	off, equal := 0, true
+	b.eachChunk(func(c []byte) bool {
+		equal = string(c) == s[off:off+len(c)]
+		off += len(c)
+		return equal
+	})
+	return equal
+}
+
+// EqualBytes 判断内容是否与 b2 相同
+func (b ByteView) EqualBytes(b2 []byte) bool {
+	if b.Len() != len(b2) {
+		return false
+	}
+	off, equal := 0, true
+	b.eachChunk(func(c []byte) bool {
+		equal = bytes.Equal(c, b2[off:off+len(c)])
+		off += len(c)
+		return equal
+	})
+	return equal
+}
+
+// Reader 返回一个只读的 io.ReadSeeker，不会拷贝数据
+// 分块存储时逐块读取，不需要连续的内存
+func (b ByteView) Reader() io.ReadSeeker {
+	if b.chunks == nil {
+		return bytes.NewReader(b.b)
+	}
+	return &chunkReader{v: b}
+}
+
+// ReadAt 实现 io.ReaderAt
+func (b ByteView) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("dcache: ByteView.ReadAt: negative offset")
+	}
+	if off >= int64(b.Len()) {
+		return 0, io.EOF
+	}
+	n = b.SliceFrom(int(off)).Copy(p)
+	if n < len(p) {
+		err = io.EOF
+	}
+	return
+}
+
+// WriteTo 实现 io.WriterTo，直接将数据写入 w，不会拷贝
+func (b ByteView) WriteTo(w io.Writer) (n int64, err error) {
+	b.eachChunk(func(c []byte) bool {
+		var m int
+		m, err = w.Write(c)
+		n += int64(m)
+		if err == nil && m != len(c) {
+			err = io.ErrShortWrite
+		}
+		return err == nil
+	})
+	return
+}
+
+// chunkReader 读取分块存储的 ByteView
+type chunkReader struct {
+	v   ByteView
+	off int64
+}
+
+func (r *chunkReader) Read(p []byte) (n int, err error) {
+	n, err = r.v.ReadAt(p, r.off)
+	r.off += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return
+}
+
+func (r *chunkReader) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = r.off + offset
+	case io.SeekEnd:
+		abs = int64(r.v.Len()) + offset
+	default:
+		return 0, errors.New("dcache: chunkReader.Seek: invalid whence")
+	}
+	if abs < 0 {
+		return 0, errors.New("dcache: chunkReader.Seek: negative position")
+	}
+	r.off = abs
+	return abs, nil
 }
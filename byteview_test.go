@@ -0,0 +1,95 @@
+package dcache
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestByteView(t *testing.T) {
+	v := ByteView{b: []byte("hello world")}
+
+	if v.At(4) != 'o' {
+		t.Fatal("At failed")
+	}
+	if s := v.Slice(0, 5); s.String() != "hello" || !s.EqualString("hello") {
+		t.Fatalf("Slice = %s", s)
+	}
+	if s := v.SliceFrom(6); !s.EqualBytes([]byte("world")) || !s.Equal(ByteView{b: []byte("world")}) {
+		t.Fatalf("SliceFrom = %s", s)
+	}
+	if v.Equal(ByteView{b: []byte("hello")}) || v.EqualString("hello") {
+		t.Fatal("Equal should fail")
+	}
+
+	dst := make([]byte, 5)
+	if n := v.Copy(dst); n != 5 || string(dst) != "hello" {
+		t.Fatal("Copy failed")
+	}
+}
+
+func TestByteView_Reader(t *testing.T) {
+	v := ByteView{b: []byte("hello world")}
+
+	r := v.Reader()
+	if _, err := r.Seek(6, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadAll(r); string(b) != "world" {
+		t.Fatalf("Reader got %q", b)
+	}
+
+	p := make([]byte, 4)
+	if n, err := v.ReadAt(p, 2); n != 4 || err != nil || string(p) != "llo " {
+		t.Fatalf("ReadAt got %q, %v", p[:n], err)
+	}
+	if n, err := v.ReadAt(p, 9); n != 2 || err != io.EOF {
+		t.Fatalf("ReadAt at end got %d, %v", n, err)
+	}
+
+	var buf bytes.Buffer
+	if n, err := v.WriteTo(&buf); n != 11 || err != nil || buf.String() != "hello world" {
+		t.Fatalf("WriteTo got %q, %v", buf.String(), err)
+	}
+}
+
+func TestByteView_Chunked(t *testing.T) {
+	v := chunkedView(splitChunks([]byte("hello world"), 4)) // hell|o wo|rld
+	if len(v.chunks) != 3 || v.Len() != 11 {
+		t.Fatalf("split into %d chunks", len(v.chunks))
+	}
+	if v.String() != "hello world" || string(v.ByteSlice()) != "hello world" {
+		t.Fatal("chunked content error")
+	}
+	if v.At(4) != 'o' || v.At(10) != 'd' {
+		t.Fatal("At failed")
+	}
+	if s := v.Slice(3, 9); s.String() != "lo wor" || !s.EqualString("lo wor") {
+		t.Fatalf("Slice = %s", s)
+	}
+	if s := v.SliceFrom(8); !s.EqualBytes([]byte("rld")) {
+		t.Fatalf("SliceFrom = %s", s)
+	}
+	flat := ByteView{b: []byte("hello world")}
+	if !v.Equal(flat) || !flat.Equal(v) || !v.Equal(chunkedView(splitChunks([]byte("hello world"), 5))) {
+		t.Fatal("chunked Equal failed")
+	}
+	if v.EqualString("hello worle") {
+		t.Fatal("EqualString should fail")
+	}
+
+	r := v.Reader()
+	r.Seek(2, io.SeekStart)
+	if b, _ := ioutil.ReadAll(r); string(b) != "llo world" {
+		t.Fatalf("Reader got %q", b)
+	}
+	p := make([]byte, 5)
+	if n, err := v.ReadAt(p, 3); n != 5 || err != nil || string(p) != "lo wo" {
+		t.Fatalf("ReadAt got %q, %v", p[:n], err)
+	}
+	var buf bytes.Buffer
+	if n, err := v.WriteTo(&buf); n != 11 || err != nil || buf.String() != "hello world" {
+		t.Fatalf("WriteTo got %q, %v", buf.String(), err)
+	}
+}
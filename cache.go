@@ -1,35 +1,273 @@
 package dcache
 
 import (
+	"dcache/cachepb"
 	lru2 "dcache/lru"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// entry 缓存中的一条记录
+type entry struct {
+	value       ByteView
+	compression cachepb.Compression // value 的压缩算法
+	checksum    uint32              // 解压后的值的 CRC32C 校验和
+	cost        time.Duration       // 从源数据加载的耗时
+	fresh       time.Time           // 在此之前记录是新鲜的，零值表示永不过期
+	tags        []string            // 记录的标签
+	gen         uint64              // 加载时 Group 的代
+	version     uint64              // 放入缓存时分配的版本，用于 CompareAndSet
+}
+
 type cache struct {
 	mu         sync.Mutex
-	lru        *lru2.Cache
+	lru        *lru2.Cache[string, entry]
 	cacheBytes int64
+	ratio      float64          // Governor 设置的缩放比例，实际上限为 cacheBytes*ratio，0 表示不缩放
+	costAware  bool             // 使用 GDSF 策略，按加载代价淘汰
+	now        func() time.Time // 判断过期使用的时钟
+
+	onEvicted func(key string, e entry, reason lru2.EvictReason) // 记录被移除时的回调，持有 mu 时调用
+	onRemoved func(key string, e entry, reason lru2.EvictReason) // 记录被移除时的回调，释放 mu 之后调用
+	removed   []removedEntry                                     // 持有 mu 期间被移除、等待调用 onRemoved 的记录
+
+	total   *int64 // 所属 Registry 中所有 Group 使用的内存之和，只能原子地访问，为 nil 时不统计
+	counted int64  // 已经计入 total 的内存
+}
+
+func (c *cache) add(key string, e entry) {
+	c.addWithExpire(key, e, time.Time{})
+}
+
+// addWithExpire 添加记录，记录在 expire 之后过期
+func (c *cache) addWithExpire(key string, e entry, expire time.Time) {
+	c.mu.Lock()
+	defer c.unlock()
+	if c.lru == nil {
+		c.init()
+	}
+	c.lru.AddWithExpire(key, e, expire)
+}
+
+func (c *cache) init() {
+	if c.costAware {
+		c.lru = lru2.NewGDSF(c.limit(), entrySize, entryCost, c.evicted)
+	} else {
+		c.lru = lru2.NewCache(c.limit(), entrySize, c.evicted)
+	}
+	c.lru.Now = c.now
+}
+
+func (c *cache) get(key string) (e entry, ok bool) {
+	c.mu.Lock()
+	defer c.unlock()
+
+	if c.lru == nil {
+		return
+	}
+
+	return c.lru.Get(key)
+}
+
+// getGen 查询第 gen 代的记录，其他代的记录视为不存在并被删除
+func (c *cache) getGen(key string, gen uint64) (e entry, ok bool) {
+	c.mu.Lock()
+	defer c.unlock()
+	if c.lru == nil {
+		return
+	}
+	if e, ok = c.lru.Peek(key); ok && e.gen != gen {
+		c.lru.Delete(key)
+		return entry{}, false
+	}
+	return c.lru.Get(key)
+}
+
+// purge 清空缓存
+func (c *cache) purge() {
+	c.mu.Lock()
+	defer c.unlock()
+	if c.lru != nil {
+		c.lru.Purge()
+	}
+}
+
+// resize 修改允许使用的最大内存
+func (c *cache) resize(cacheBytes int64) {
+	c.mu.Lock()
+	defer c.unlock()
+	c.cacheBytes = cacheBytes
+	if c.lru != nil {
+		c.lru.Resize(c.limit())
+	}
+}
+
+// scale 按比例缩放实际的内存上限，不改变设置的 cacheBytes
+func (c *cache) scale(ratio float64) {
+	c.mu.Lock()
+	defer c.unlock()
+	c.ratio = ratio
+	if c.lru != nil {
+		c.lru.Resize(c.limit())
+	}
+}
+
+// limit 实际的内存上限，调用时需要持有 mu
+func (c *cache) limit() int64 {
+	if c.cacheBytes <= 0 || c.ratio <= 0 || c.ratio >= 1 {
+		return c.cacheBytes
+	}
+	// 至少为 1，0 表示不限制
+	if n := int64(float64(c.cacheBytes) * c.ratio); n > 0 {
+		return n
+	}
+	return 1
 }
 
-func (c *cache) add(key string, value ByteView) {
+// removedEntry 等待调用 onRemoved 的记录
+type removedEntry struct {
+	key    string
+	entry  entry
+	reason lru2.EvictReason
+}
+
+// evicted lru 移除记录时的回调，持有 mu
+func (c *cache) evicted(key string, e entry, reason lru2.EvictReason) {
+	if c.onEvicted != nil {
+		c.onEvicted(key, e, reason)
+	}
+	if c.onRemoved != nil {
+		c.removed = append(c.removed, removedEntry{key: key, entry: e, reason: reason})
+	}
+}
+
+// unlock 更新使用的内存并释放 mu，之后再对期间被移除的记录调用 onRemoved
+func (c *cache) unlock() {
+	c.account()
+	removed := c.removed
+	c.removed = nil
+	c.mu.Unlock()
+	for _, r := range removed {
+		c.onRemoved(r.key, r.entry, r.reason)
+	}
+}
+
+// account 将使用的内存的变化计入 total，调用时需要持有 mu
+func (c *cache) account() {
+	if c.total == nil {
+		return
+	}
+	var used int64
+	if c.lru != nil {
+		used = c.lru.Bytes()
+	}
+	if delta := used - c.counted; delta != 0 {
+		atomic.AddInt64(c.total, delta)
+		c.counted = used
+	}
+}
+
+// track 开始将使用的内存计入 total，total 为 nil 时停止统计并减去已经计入的内存
+func (c *cache) track(total *int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.total != nil {
+		atomic.AddInt64(c.total, -c.counted)
+	}
+	c.total, c.counted = total, 0
+	c.account()
+}
+
+// bytes 当前使用的内存
+func (c *cache) bytes() int64 {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	if c.lru == nil {
-		c.lru = lru2.New(c.cacheBytes, nil)
+		return 0
 	}
-	c.lru.Add(key, value)
+	return c.lru.Bytes()
 }
 
-func (c *cache) get(key string) (value ByteView, ok bool) {
+// cachedEntry 导出快照时的一条记录
+type cachedEntry struct {
+	key    string
+	entry  entry
+	expire time.Time
+}
+
+// entries 从最久未访问的记录开始，返回所有未过期的记录
+// 记录中的值只读，释放锁之后仍可以使用
+func (c *cache) entries() []cachedEntry {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	if c.lru == nil {
+		return nil
+	}
+	entries := make([]cachedEntry, 0, c.lru.Len())
+	c.lru.RangeOldest(func(key string, e entry, expire time.Time) bool {
+		entries = append(entries, cachedEntry{key: key, entry: e, expire: expire})
+		return true
+	})
+	return entries
+}
 
+// remove 删除一条记录，返回记录是否存在
+func (c *cache) remove(key string) bool {
+	c.mu.Lock()
+	defer c.unlock()
 	if c.lru == nil {
-		return
+		return false
+	}
+	return c.lru.Delete(key)
+}
+
+// removePrefix 删除所有以 prefix 为前缀的记录，返回删除的条数
+func (c *cache) removePrefix(prefix string) int {
+	c.mu.Lock()
+	defer c.unlock()
+	if c.lru == nil {
+		return 0
+	}
+	n := 0
+	for _, key := range c.lru.Keys() {
+		if strings.HasPrefix(key, prefix) && c.lru.Delete(key) {
+			n++
+		}
+	}
+	return n
+}
+
+// removeOldest 淘汰一条记录，没有记录时返回 false
+func (c *cache) removeOldest() bool {
+	c.mu.Lock()
+	defer c.unlock()
+	if c.lru == nil || c.lru.Len() == 0 {
+		return false
 	}
+	c.lru.Remove()
+	return true
+}
+
+func (c *cache) maxBytes() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cacheBytes
+}
+
+// entryOverhead 每条记录在 lru 中额外占用的内存
+var entryOverhead = lru2.Overhead[string, entry]()
+
+// entrySize 一条记录占用的内存，包括 key、value 以及 lru 的额外开销
+func entrySize(key string, e entry) int64 {
+	return int64(len(key)) + int64(e.value.Len()) + entryOverhead
+}
 
-	if v, ok := c.lru.Get(key); ok {
-		return v.(ByteView), ok
+// entryCost 一条记录的加载代价，至少为 1
+func entryCost(key string, e entry) float64 {
+	if e.cost < 1 {
+		return 1
 	}
-	return
+	return float64(e.cost)
 }
@@ -24,9 +24,40 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 
+// Compression value 使用的压缩算法
+type Compression int32
+
+const (
+	Compression_NONE   Compression = 0
+	Compression_GZIP   Compression = 1
+	Compression_SNAPPY Compression = 2
+)
+
+var Compression_name = map[int32]string{
+	0: "NONE",
+	1: "GZIP",
+	2: "SNAPPY",
+}
+
+var Compression_value = map[string]int32{
+	"NONE":   0,
+	"GZIP":   1,
+	"SNAPPY": 2,
+}
+
+func (x Compression) String() string {
+	return proto.EnumName(Compression_name, int32(x))
+}
+
+func (Compression) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_65b4d2f9fe4de76d, []int{0}
+}
+
 type Request struct {
 	Group                string   `protobuf:"bytes,1,opt,name=group,proto3" json:"group,omitempty"`
 	Key                  string   `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	Generation           uint64   `protobuf:"varint,3,opt,name=generation,proto3" json:"generation,omitempty"`
+	MaxSize              uint64   `protobuf:"varint,4,opt,name=max_size,json=maxSize,proto3" json:"max_size,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -71,11 +102,32 @@ func (m *Request) GetKey() string {
 	return ""
 }
 
+func (m *Request) GetGeneration() uint64 {
+	if m != nil {
+		return m.Generation
+	}
+	return 0
+}
+
+func (m *Request) GetMaxSize() uint64 {
+	if m != nil {
+		return m.MaxSize
+	}
+	return 0
+}
+
 type Response struct {
-	Value                []byte   `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+	Value                []byte      `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	NotFound             bool        `protobuf:"varint,2,opt,name=not_found,json=notFound,proto3" json:"not_found,omitempty"`
+	Compression          Compression `protobuf:"varint,3,opt,name=compression,proto3,enum=cachepb.Compression" json:"compression,omitempty"`
+	Checksum             uint32      `protobuf:"varint,4,opt,name=checksum,proto3" json:"checksum,omitempty"`
+	LeaseHeld            bool        `protobuf:"varint,5,opt,name=lease_held,json=leaseHeld,proto3" json:"lease_held,omitempty"`
+	Version              uint64      `protobuf:"varint,6,opt,name=version,proto3" json:"version,omitempty"`
+	Size                 uint64      `protobuf:"varint,7,opt,name=size,proto3" json:"size,omitempty"`
+	Error                string      `protobuf:"bytes,8,opt,name=error,proto3" json:"error,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}    `json:"-"`
+	XXX_unrecognized     []byte      `json:"-"`
+	XXX_sizecache        int32       `json:"-"`
 }
 
 func (m *Response) Reset()         { *m = Response{} }
@@ -110,25 +162,604 @@ func (m *Response) GetValue() []byte {
 	return nil
 }
 
+func (m *Response) GetNotFound() bool {
+	if m != nil {
+		return m.NotFound
+	}
+	return false
+}
+
+func (m *Response) GetCompression() Compression {
+	if m != nil {
+		return m.Compression
+	}
+	return Compression_NONE
+}
+
+func (m *Response) GetChecksum() uint32 {
+	if m != nil {
+		return m.Checksum
+	}
+	return 0
+}
+
+func (m *Response) GetLeaseHeld() bool {
+	if m != nil {
+		return m.LeaseHeld
+	}
+	return false
+}
+
+func (m *Response) GetVersion() uint64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *Response) GetSize() uint64 {
+	if m != nil {
+		return m.Size
+	}
+	return 0
+}
+
+func (m *Response) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+type BatchRequest struct {
+	Group                string   `protobuf:"bytes,1,opt,name=group,proto3" json:"group,omitempty"`
+	Keys                 []string `protobuf:"bytes,2,rep,name=keys,proto3" json:"keys,omitempty"`
+	Generation           uint64   `protobuf:"varint,3,opt,name=generation,proto3" json:"generation,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *BatchRequest) Reset()         { *m = BatchRequest{} }
+func (m *BatchRequest) String() string { return proto.CompactTextString(m) }
+func (*BatchRequest) ProtoMessage()    {}
+func (*BatchRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_65b4d2f9fe4de76d, []int{2}
+}
+
+func (m *BatchRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BatchRequest.Unmarshal(m, b)
+}
+func (m *BatchRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BatchRequest.Marshal(b, m, deterministic)
+}
+func (m *BatchRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BatchRequest.Merge(m, src)
+}
+func (m *BatchRequest) XXX_Size() int {
+	return xxx_messageInfo_BatchRequest.Size(m)
+}
+func (m *BatchRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_BatchRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BatchRequest proto.InternalMessageInfo
+
+func (m *BatchRequest) GetGroup() string {
+	if m != nil {
+		return m.Group
+	}
+	return ""
+}
+
+func (m *BatchRequest) GetKeys() []string {
+	if m != nil {
+		return m.Keys
+	}
+	return nil
+}
+
+func (m *BatchRequest) GetGeneration() uint64 {
+	if m != nil {
+		return m.Generation
+	}
+	return 0
+}
+
+type BatchResponse struct {
+	Values               map[string]*Response `protobuf:"bytes,1,rep,name=values,proto3" json:"values,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *BatchResponse) Reset()         { *m = BatchResponse{} }
+func (m *BatchResponse) String() string { return proto.CompactTextString(m) }
+func (*BatchResponse) ProtoMessage()    {}
+func (*BatchResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_65b4d2f9fe4de76d, []int{3}
+}
+
+func (m *BatchResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BatchResponse.Unmarshal(m, b)
+}
+func (m *BatchResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BatchResponse.Marshal(b, m, deterministic)
+}
+func (m *BatchResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BatchResponse.Merge(m, src)
+}
+func (m *BatchResponse) XXX_Size() int {
+	return xxx_messageInfo_BatchResponse.Size(m)
+}
+func (m *BatchResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_BatchResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BatchResponse proto.InternalMessageInfo
+
+func (m *BatchResponse) GetValues() map[string]*Response {
+	if m != nil {
+		return m.Values
+	}
+	return nil
+}
+
+// Chunk 分块传输时的一块数据，第一块携带 header
+type Chunk struct {
+	Header               *Response `protobuf:"bytes,1,opt,name=header,proto3" json:"header,omitempty"`
+	Data                 []byte    `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
+	XXX_unrecognized     []byte    `json:"-"`
+	XXX_sizecache        int32     `json:"-"`
+}
+
+func (m *Chunk) Reset()         { *m = Chunk{} }
+func (m *Chunk) String() string { return proto.CompactTextString(m) }
+func (*Chunk) ProtoMessage()    {}
+func (*Chunk) Descriptor() ([]byte, []int) {
+	return fileDescriptor_65b4d2f9fe4de76d, []int{4}
+}
+
+func (m *Chunk) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Chunk.Unmarshal(m, b)
+}
+func (m *Chunk) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Chunk.Marshal(b, m, deterministic)
+}
+func (m *Chunk) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Chunk.Merge(m, src)
+}
+func (m *Chunk) XXX_Size() int {
+	return xxx_messageInfo_Chunk.Size(m)
+}
+func (m *Chunk) XXX_DiscardUnknown() {
+	xxx_messageInfo_Chunk.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Chunk proto.InternalMessageInfo
+
+func (m *Chunk) GetHeader() *Response {
+	if m != nil {
+		return m.Header
+	}
+	return nil
+}
+
+func (m *Chunk) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+// InvalidateRequest 删除 key 对应的缓存，prefix 为 true 时删除所有以 key 为前缀的缓存，
+// tag 不为空时删除所有带有该标签的缓存，忽略 key 和 prefix，
+// generation 不为0时将 Group 的代更新为该值，之前的缓存全部失效，忽略其他字段
+type InvalidateRequest struct {
+	Group                string   `protobuf:"bytes,1,opt,name=group,proto3" json:"group,omitempty"`
+	Key                  string   `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	Prefix               bool     `protobuf:"varint,3,opt,name=prefix,proto3" json:"prefix,omitempty"`
+	Tag                  string   `protobuf:"bytes,4,opt,name=tag,proto3" json:"tag,omitempty"`
+	Generation           uint64   `protobuf:"varint,5,opt,name=generation,proto3" json:"generation,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *InvalidateRequest) Reset()         { *m = InvalidateRequest{} }
+func (m *InvalidateRequest) String() string { return proto.CompactTextString(m) }
+func (*InvalidateRequest) ProtoMessage()    {}
+func (*InvalidateRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_65b4d2f9fe4de76d, []int{5}
+}
+
+func (m *InvalidateRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_InvalidateRequest.Unmarshal(m, b)
+}
+func (m *InvalidateRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_InvalidateRequest.Marshal(b, m, deterministic)
+}
+func (m *InvalidateRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_InvalidateRequest.Merge(m, src)
+}
+func (m *InvalidateRequest) XXX_Size() int {
+	return xxx_messageInfo_InvalidateRequest.Size(m)
+}
+func (m *InvalidateRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_InvalidateRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_InvalidateRequest proto.InternalMessageInfo
+
+func (m *InvalidateRequest) GetGroup() string {
+	if m != nil {
+		return m.Group
+	}
+	return ""
+}
+
+func (m *InvalidateRequest) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+func (m *InvalidateRequest) GetPrefix() bool {
+	if m != nil {
+		return m.Prefix
+	}
+	return false
+}
+
+func (m *InvalidateRequest) GetTag() string {
+	if m != nil {
+		return m.Tag
+	}
+	return ""
+}
+
+func (m *InvalidateRequest) GetGeneration() uint64 {
+	if m != nil {
+		return m.Generation
+	}
+	return 0
+}
+
+type InvalidateResponse struct {
+	Removed              int64    `protobuf:"varint,1,opt,name=removed,proto3" json:"removed,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *InvalidateResponse) Reset()         { *m = InvalidateResponse{} }
+func (m *InvalidateResponse) String() string { return proto.CompactTextString(m) }
+func (*InvalidateResponse) ProtoMessage()    {}
+func (*InvalidateResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_65b4d2f9fe4de76d, []int{6}
+}
+
+func (m *InvalidateResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_InvalidateResponse.Unmarshal(m, b)
+}
+func (m *InvalidateResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_InvalidateResponse.Marshal(b, m, deterministic)
+}
+func (m *InvalidateResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_InvalidateResponse.Merge(m, src)
+}
+func (m *InvalidateResponse) XXX_Size() int {
+	return xxx_messageInfo_InvalidateResponse.Size(m)
+}
+func (m *InvalidateResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_InvalidateResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_InvalidateResponse proto.InternalMessageInfo
+
+func (m *InvalidateResponse) GetRemoved() int64 {
+	if m != nil {
+		return m.Removed
+	}
+	return 0
+}
+
+// PutRequest 写入 key，只发送给 key 所属的节点
+type PutRequest struct {
+	Group                string   `protobuf:"bytes,1,opt,name=group,proto3" json:"group,omitempty"`
+	Key                  string   `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	Value                []byte   `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`
+	Tags                 []string `protobuf:"bytes,4,rep,name=tags,proto3" json:"tags,omitempty"`
+	Generation           uint64   `protobuf:"varint,5,opt,name=generation,proto3" json:"generation,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *PutRequest) Reset()         { *m = PutRequest{} }
+func (m *PutRequest) String() string { return proto.CompactTextString(m) }
+func (*PutRequest) ProtoMessage()    {}
+func (*PutRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_65b4d2f9fe4de76d, []int{7}
+}
+
+func (m *PutRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PutRequest.Unmarshal(m, b)
+}
+func (m *PutRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PutRequest.Marshal(b, m, deterministic)
+}
+func (m *PutRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PutRequest.Merge(m, src)
+}
+func (m *PutRequest) XXX_Size() int {
+	return xxx_messageInfo_PutRequest.Size(m)
+}
+func (m *PutRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_PutRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PutRequest proto.InternalMessageInfo
+
+func (m *PutRequest) GetGroup() string {
+	if m != nil {
+		return m.Group
+	}
+	return ""
+}
+
+func (m *PutRequest) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+func (m *PutRequest) GetValue() []byte {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+func (m *PutRequest) GetTags() []string {
+	if m != nil {
+		return m.Tags
+	}
+	return nil
+}
+
+func (m *PutRequest) GetGeneration() uint64 {
+	if m != nil {
+		return m.Generation
+	}
+	return 0
+}
+
+type PutResponse struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *PutResponse) Reset()         { *m = PutResponse{} }
+func (m *PutResponse) String() string { return proto.CompactTextString(m) }
+func (*PutResponse) ProtoMessage()    {}
+func (*PutResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_65b4d2f9fe4de76d, []int{8}
+}
+
+func (m *PutResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PutResponse.Unmarshal(m, b)
+}
+func (m *PutResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PutResponse.Marshal(b, m, deterministic)
+}
+func (m *PutResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PutResponse.Merge(m, src)
+}
+func (m *PutResponse) XXX_Size() int {
+	return xxx_messageInfo_PutResponse.Size(m)
+}
+func (m *PutResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_PutResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PutResponse proto.InternalMessageInfo
+
+// CompareAndSetRequest 记录的版本等于 version 时写入新的值
+type CompareAndSetRequest struct {
+	Group                string   `protobuf:"bytes,1,opt,name=group,proto3" json:"group,omitempty"`
+	Key                  string   `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	Value                []byte   `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`
+	Version              uint64   `protobuf:"varint,4,opt,name=version,proto3" json:"version,omitempty"`
+	Tags                 []string `protobuf:"bytes,5,rep,name=tags,proto3" json:"tags,omitempty"`
+	Generation           uint64   `protobuf:"varint,6,opt,name=generation,proto3" json:"generation,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CompareAndSetRequest) Reset()         { *m = CompareAndSetRequest{} }
+func (m *CompareAndSetRequest) String() string { return proto.CompactTextString(m) }
+func (*CompareAndSetRequest) ProtoMessage()    {}
+func (*CompareAndSetRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_65b4d2f9fe4de76d, []int{9}
+}
+
+func (m *CompareAndSetRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CompareAndSetRequest.Unmarshal(m, b)
+}
+func (m *CompareAndSetRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CompareAndSetRequest.Marshal(b, m, deterministic)
+}
+func (m *CompareAndSetRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CompareAndSetRequest.Merge(m, src)
+}
+func (m *CompareAndSetRequest) XXX_Size() int {
+	return xxx_messageInfo_CompareAndSetRequest.Size(m)
+}
+func (m *CompareAndSetRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_CompareAndSetRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CompareAndSetRequest proto.InternalMessageInfo
+
+func (m *CompareAndSetRequest) GetGroup() string {
+	if m != nil {
+		return m.Group
+	}
+	return ""
+}
+
+func (m *CompareAndSetRequest) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+func (m *CompareAndSetRequest) GetValue() []byte {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+func (m *CompareAndSetRequest) GetVersion() uint64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *CompareAndSetRequest) GetTags() []string {
+	if m != nil {
+		return m.Tags
+	}
+	return nil
+}
+
+func (m *CompareAndSetRequest) GetGeneration() uint64 {
+	if m != nil {
+		return m.Generation
+	}
+	return 0
+}
+
+type CompareAndSetResponse struct {
+	Version              uint64   `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	Conflict             bool     `protobuf:"varint,2,opt,name=conflict,proto3" json:"conflict,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CompareAndSetResponse) Reset()         { *m = CompareAndSetResponse{} }
+func (m *CompareAndSetResponse) String() string { return proto.CompactTextString(m) }
+func (*CompareAndSetResponse) ProtoMessage()    {}
+func (*CompareAndSetResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_65b4d2f9fe4de76d, []int{10}
+}
+
+func (m *CompareAndSetResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CompareAndSetResponse.Unmarshal(m, b)
+}
+func (m *CompareAndSetResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CompareAndSetResponse.Marshal(b, m, deterministic)
+}
+func (m *CompareAndSetResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CompareAndSetResponse.Merge(m, src)
+}
+func (m *CompareAndSetResponse) XXX_Size() int {
+	return xxx_messageInfo_CompareAndSetResponse.Size(m)
+}
+func (m *CompareAndSetResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_CompareAndSetResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CompareAndSetResponse proto.InternalMessageInfo
+
+func (m *CompareAndSetResponse) GetVersion() uint64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *CompareAndSetResponse) GetConflict() bool {
+	if m != nil {
+		return m.Conflict
+	}
+	return false
+}
+
 func init() {
+	proto.RegisterEnum("cachepb.Compression", Compression_name, Compression_value)
 	proto.RegisterType((*Request)(nil), "cachepb.Request")
 	proto.RegisterType((*Response)(nil), "cachepb.Response")
+	proto.RegisterType((*BatchRequest)(nil), "cachepb.BatchRequest")
+	proto.RegisterType((*BatchResponse)(nil), "cachepb.BatchResponse")
+	proto.RegisterMapType((map[string]*Response)(nil), "cachepb.BatchResponse.ValuesEntry")
+	proto.RegisterType((*Chunk)(nil), "cachepb.Chunk")
+	proto.RegisterType((*InvalidateRequest)(nil), "cachepb.InvalidateRequest")
+	proto.RegisterType((*InvalidateResponse)(nil), "cachepb.InvalidateResponse")
+	proto.RegisterType((*PutRequest)(nil), "cachepb.PutRequest")
+	proto.RegisterType((*PutResponse)(nil), "cachepb.PutResponse")
+	proto.RegisterType((*CompareAndSetRequest)(nil), "cachepb.CompareAndSetRequest")
+	proto.RegisterType((*CompareAndSetResponse)(nil), "cachepb.CompareAndSetResponse")
 }
 
 func init() { proto.RegisterFile("cachepb.proto", fileDescriptor_65b4d2f9fe4de76d) }
 
 var fileDescriptor_65b4d2f9fe4de76d = []byte{
-	// 145 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0xe2, 0x4d, 0x4e, 0x4c, 0xce,
-	0x48, 0x2d, 0x48, 0xd2, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0x62, 0x87, 0x72, 0x95, 0x0c, 0xb9,
-	0xd8, 0x83, 0x52, 0x0b, 0x4b, 0x53, 0x8b, 0x4b, 0x84, 0x44, 0xb8, 0x58, 0xd3, 0x8b, 0xf2, 0x4b,
-	0x0b, 0x24, 0x18, 0x15, 0x18, 0x35, 0x38, 0x83, 0x20, 0x1c, 0x21, 0x01, 0x2e, 0xe6, 0xec, 0xd4,
-	0x4a, 0x09, 0x26, 0xb0, 0x18, 0x88, 0xa9, 0xa4, 0xc0, 0xc5, 0x11, 0x94, 0x5a, 0x5c, 0x90, 0x9f,
-	0x57, 0x9c, 0x0a, 0xd2, 0x53, 0x96, 0x98, 0x53, 0x9a, 0x0a, 0xd6, 0xc3, 0x13, 0x04, 0xe1, 0x18,
-	0x59, 0x70, 0x71, 0xb9, 0x83, 0x34, 0x3b, 0x83, 0x2c, 0x11, 0xd2, 0xe2, 0x62, 0x76, 0x4f, 0x2d,
-	0x11, 0x12, 0xd0, 0x83, 0x39, 0x01, 0x6a, 0xa1, 0x94, 0x20, 0x92, 0x08, 0xc4, 0xbc, 0x24, 0x36,
-	0xb0, 0xf3, 0x8c, 0x01, 0x01, 0x00, 0x00, 0xff, 0xff, 0x0d, 0x21, 0x50, 0x8d, 0xaf, 0x00, 0x00,
-	0x00,
+	// 703 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x55, 0x41, 0x6f, 0xda, 0x4a,
+	0x10, 0x7e, 0x8b, 0x0d, 0x98, 0x21, 0x44, 0x64, 0x1f, 0x89, 0xfc, 0x88, 0x12, 0x21, 0x5f, 0x1e,
+	0x8d, 0x54, 0x1a, 0x51, 0xa9, 0x6a, 0x73, 0x4b, 0xa3, 0x84, 0x46, 0x6a, 0x29, 0x5a, 0xa4, 0xaa,
+	0xed, 0x25, 0xda, 0xe0, 0x09, 0x20, 0xc0, 0xa6, 0xeb, 0x35, 0x4a, 0x72, 0xe8, 0xad, 0x3f, 0xa2,
+	0xd7, 0xfe, 0xbf, 0xde, 0xfa, 0x03, 0xaa, 0x5d, 0xdb, 0x60, 0x20, 0x69, 0x14, 0xa9, 0xb7, 0x99,
+	0xdd, 0xd9, 0xfd, 0xbe, 0xfd, 0x66, 0x3e, 0x1b, 0x4a, 0x3d, 0xde, 0x1b, 0xe0, 0xf4, 0xb2, 0x31,
+	0x15, 0xbe, 0xf4, 0x69, 0x3e, 0x4e, 0x9d, 0x31, 0xe4, 0x19, 0x7e, 0x09, 0x31, 0x90, 0xb4, 0x02,
+	0xd9, 0xbe, 0xf0, 0xc3, 0xa9, 0x4d, 0x6a, 0xa4, 0x5e, 0x60, 0x51, 0x42, 0xcb, 0x60, 0x8c, 0xf0,
+	0xc6, 0xce, 0xe8, 0x35, 0x15, 0xd2, 0x7d, 0x80, 0x3e, 0x7a, 0x28, 0xb8, 0x1c, 0xfa, 0x9e, 0x6d,
+	0xd4, 0x48, 0xdd, 0x64, 0xa9, 0x15, 0xfa, 0x1f, 0x58, 0x13, 0x7e, 0x7d, 0x11, 0x0c, 0x6f, 0xd1,
+	0x36, 0xf5, 0x6e, 0x7e, 0xc2, 0xaf, 0xbb, 0xc3, 0x5b, 0x74, 0x7e, 0x11, 0xb0, 0x18, 0x06, 0x53,
+	0xdf, 0x0b, 0x50, 0xe1, 0xcd, 0xf8, 0x38, 0x44, 0x8d, 0xb7, 0xc1, 0xa2, 0x84, 0xee, 0x42, 0xc1,
+	0xf3, 0xe5, 0xc5, 0x95, 0x1f, 0x7a, 0xae, 0x46, 0xb5, 0x98, 0xe5, 0xf9, 0xf2, 0x4c, 0xe5, 0xf4,
+	0x05, 0x14, 0x7b, 0xfe, 0x64, 0x2a, 0x30, 0x08, 0x12, 0xec, 0xcd, 0x66, 0xa5, 0x91, 0xbc, 0xed,
+	0x64, 0xb1, 0xc7, 0xd2, 0x85, 0xb4, 0x0a, 0x56, 0x6f, 0x80, 0xbd, 0x51, 0x10, 0x4e, 0x34, 0xa5,
+	0x12, 0x9b, 0xe7, 0x74, 0x0f, 0x60, 0x8c, 0x3c, 0xc0, 0x8b, 0x01, 0x8e, 0x5d, 0x3b, 0xab, 0x11,
+	0x0b, 0x7a, 0xe5, 0x0d, 0x8e, 0x5d, 0x6a, 0x43, 0x7e, 0x86, 0x42, 0xc3, 0xe5, 0xa2, 0xc7, 0xc4,
+	0x29, 0xa5, 0x60, 0xea, 0x37, 0xe6, 0xf5, 0xb2, 0x8e, 0xd5, 0x9b, 0x50, 0x08, 0x5f, 0xd8, 0x56,
+	0xa4, 0xa1, 0x4e, 0x9c, 0x8f, 0xb0, 0xf1, 0x9a, 0xcb, 0xde, 0xe0, 0xcf, 0x4a, 0x53, 0x30, 0x47,
+	0x78, 0x13, 0xd8, 0x99, 0x9a, 0x51, 0x2f, 0x30, 0x1d, 0x3f, 0xa4, 0xb5, 0xf3, 0x9d, 0x40, 0x29,
+	0xbe, 0x3a, 0x56, 0xf5, 0x08, 0x72, 0x5a, 0xc8, 0xc0, 0x26, 0x35, 0xa3, 0x5e, 0x6c, 0x3a, 0x73,
+	0x75, 0x96, 0xea, 0x1a, 0x1f, 0x74, 0xd1, 0xa9, 0x27, 0xc5, 0x0d, 0x8b, 0x4f, 0x54, 0xdf, 0x42,
+	0x31, 0xb5, 0x9c, 0xb4, 0x9e, 0x2c, 0x5a, 0xff, 0x7f, 0xd2, 0x32, 0xd5, 0x98, 0x62, 0x73, 0x6b,
+	0x7e, 0x77, 0x72, 0x6d, 0xdc, 0xc5, 0xa3, 0xcc, 0x4b, 0xe2, 0x9c, 0x41, 0xf6, 0x64, 0x10, 0x7a,
+	0x23, 0xfa, 0x04, 0x72, 0x03, 0xe4, 0x2e, 0x0a, 0x9b, 0xdc, 0x77, 0x2c, 0x2e, 0x50, 0x1a, 0xb8,
+	0x5c, 0x72, 0x7d, 0xff, 0x06, 0xd3, 0xb1, 0xf3, 0x8d, 0xc0, 0xd6, 0xb9, 0x37, 0xe3, 0xe3, 0xa1,
+	0xcb, 0x25, 0x3e, 0x76, 0x5a, 0x77, 0x20, 0x37, 0x15, 0x78, 0x35, 0xbc, 0xd6, 0xea, 0x59, 0x2c,
+	0xce, 0x54, 0xa5, 0xe4, 0x7d, 0x3d, 0x0d, 0x05, 0xa6, 0xc2, 0x15, 0xad, 0xb3, 0x6b, 0x5a, 0x37,
+	0x80, 0xa6, 0x69, 0xc4, 0x7a, 0xdb, 0x90, 0x17, 0x38, 0xf1, 0x67, 0xe8, 0x6a, 0x26, 0x06, 0x4b,
+	0x52, 0xe7, 0x2b, 0x40, 0x27, 0x94, 0x8f, 0xe5, 0x3b, 0x77, 0x85, 0x91, 0x76, 0x05, 0x05, 0x53,
+	0xf2, 0x7e, 0x60, 0x9b, 0xd1, 0x6c, 0xa8, 0xf8, 0x41, 0xbe, 0x25, 0x28, 0x6a, 0xfc, 0x88, 0xa8,
+	0xf3, 0x83, 0x40, 0x45, 0x19, 0x84, 0x0b, 0x3c, 0xf6, 0xdc, 0x2e, 0xfe, 0x25, 0x66, 0x29, 0x7f,
+	0x98, 0x6b, 0xfe, 0xd0, 0x9c, 0xb3, 0xf7, 0x72, 0xce, 0xad, 0x71, 0x7e, 0x07, 0xdb, 0x2b, 0x1c,
+	0x17, 0x32, 0x27, 0x30, 0x64, 0x19, 0x46, 0x79, 0xdb, 0xf7, 0xae, 0xc6, 0xc3, 0x9e, 0x4c, 0xbe,
+	0x17, 0x49, 0x7e, 0xf0, 0x14, 0x8a, 0xa9, 0x6f, 0x02, 0xb5, 0xc0, 0x6c, 0xbf, 0x6f, 0x9f, 0x96,
+	0xff, 0x51, 0x51, 0xeb, 0xf3, 0x79, 0xa7, 0x4c, 0x28, 0x40, 0xae, 0xdb, 0x3e, 0xee, 0x74, 0x3e,
+	0x95, 0x33, 0xcd, 0x9f, 0x19, 0x80, 0x96, 0x7a, 0xfd, 0x89, 0x9a, 0x4f, 0x7a, 0x00, 0x46, 0x0b,
+	0x25, 0x2d, 0xa7, 0xc6, 0x55, 0x2b, 0x56, 0x5d, 0x1f, 0x60, 0xfa, 0x0a, 0x2c, 0xed, 0x2f, 0x75,
+	0x60, 0x7b, 0xd5, 0x72, 0xd1, 0xa9, 0x9d, 0xbb, 0x9d, 0x48, 0x9f, 0x41, 0xa1, 0x85, 0xb2, 0x2b,
+	0x05, 0xf2, 0xc9, 0x1d, 0x60, 0x9b, 0x8b, 0xcf, 0x9b, 0x72, 0xd3, 0x21, 0xa1, 0xa7, 0x00, 0x8b,
+	0x41, 0xa4, 0xd5, 0xf9, 0xfe, 0x9a, 0x49, 0xaa, 0xbb, 0x77, 0xee, 0xc5, 0xb8, 0x87, 0x60, 0x74,
+	0x42, 0x49, 0xff, 0x9d, 0xd7, 0x2c, 0xa6, 0xb5, 0x5a, 0x59, 0x5e, 0x8c, 0x4f, 0xb4, 0xa1, 0xb4,
+	0xd4, 0x1d, 0xba, 0xb7, 0xf4, 0xe9, 0x5d, 0x9d, 0xac, 0xea, 0xfe, 0x7d, 0xdb, 0xd1, 0x7d, 0x97,
+	0x39, 0xfd, 0x33, 0x7a, 0xfe, 0x7b, 0x00, 0x3f, 0x76, 0x22, 0xe0, 0x9d, 0x06, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -144,6 +775,11 @@ const _ = grpc.SupportPackageIsVersion4
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
 type GroupCacheClient interface {
 	Get(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Response, error)
+	BatchGet(ctx context.Context, in *BatchRequest, opts ...grpc.CallOption) (*BatchResponse, error)
+	GetStream(ctx context.Context, in *Request, opts ...grpc.CallOption) (GroupCache_GetStreamClient, error)
+	Invalidate(ctx context.Context, in *InvalidateRequest, opts ...grpc.CallOption) (*InvalidateResponse, error)
+	Put(ctx context.Context, in *PutRequest, opts ...grpc.CallOption) (*PutResponse, error)
+	CompareAndSet(ctx context.Context, in *CompareAndSetRequest, opts ...grpc.CallOption) (*CompareAndSetResponse, error)
 }
 
 type groupCacheClient struct {
@@ -163,9 +799,82 @@ func (c *groupCacheClient) Get(ctx context.Context, in *Request, opts ...grpc.Ca
 	return out, nil
 }
 
+func (c *groupCacheClient) BatchGet(ctx context.Context, in *BatchRequest, opts ...grpc.CallOption) (*BatchResponse, error) {
+	out := new(BatchResponse)
+	err := c.cc.Invoke(ctx, "/cachepb.GroupCache/BatchGet", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *groupCacheClient) GetStream(ctx context.Context, in *Request, opts ...grpc.CallOption) (GroupCache_GetStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_GroupCache_serviceDesc.Streams[0], "/cachepb.GroupCache/GetStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &groupCacheGetStreamClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type GroupCache_GetStreamClient interface {
+	Recv() (*Chunk, error)
+	grpc.ClientStream
+}
+
+type groupCacheGetStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *groupCacheGetStreamClient) Recv() (*Chunk, error) {
+	m := new(Chunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *groupCacheClient) Invalidate(ctx context.Context, in *InvalidateRequest, opts ...grpc.CallOption) (*InvalidateResponse, error) {
+	out := new(InvalidateResponse)
+	err := c.cc.Invoke(ctx, "/cachepb.GroupCache/Invalidate", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *groupCacheClient) Put(ctx context.Context, in *PutRequest, opts ...grpc.CallOption) (*PutResponse, error) {
+	out := new(PutResponse)
+	err := c.cc.Invoke(ctx, "/cachepb.GroupCache/Put", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *groupCacheClient) CompareAndSet(ctx context.Context, in *CompareAndSetRequest, opts ...grpc.CallOption) (*CompareAndSetResponse, error) {
+	out := new(CompareAndSetResponse)
+	err := c.cc.Invoke(ctx, "/cachepb.GroupCache/CompareAndSet", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // GroupCacheServer is the server API for GroupCache service.
 type GroupCacheServer interface {
 	Get(context.Context, *Request) (*Response, error)
+	BatchGet(context.Context, *BatchRequest) (*BatchResponse, error)
+	GetStream(*Request, GroupCache_GetStreamServer) error
+	Invalidate(context.Context, *InvalidateRequest) (*InvalidateResponse, error)
+	Put(context.Context, *PutRequest) (*PutResponse, error)
+	CompareAndSet(context.Context, *CompareAndSetRequest) (*CompareAndSetResponse, error)
 }
 
 // UnimplementedGroupCacheServer can be embedded to have forward compatible implementations.
@@ -175,6 +884,21 @@ type UnimplementedGroupCacheServer struct {
 func (*UnimplementedGroupCacheServer) Get(ctx context.Context, req *Request) (*Response, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
 }
+func (*UnimplementedGroupCacheServer) BatchGet(ctx context.Context, req *BatchRequest) (*BatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchGet not implemented")
+}
+func (*UnimplementedGroupCacheServer) GetStream(req *Request, srv GroupCache_GetStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method GetStream not implemented")
+}
+func (*UnimplementedGroupCacheServer) Invalidate(ctx context.Context, req *InvalidateRequest) (*InvalidateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Invalidate not implemented")
+}
+func (*UnimplementedGroupCacheServer) Put(ctx context.Context, req *PutRequest) (*PutResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Put not implemented")
+}
+func (*UnimplementedGroupCacheServer) CompareAndSet(ctx context.Context, req *CompareAndSetRequest) (*CompareAndSetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CompareAndSet not implemented")
+}
 
 func RegisterGroupCacheServer(s *grpc.Server, srv GroupCacheServer) {
 	s.RegisterService(&_GroupCache_serviceDesc, srv)
@@ -198,6 +922,99 @@ func _GroupCache_Get_Handler(srv interface{}, ctx context.Context, dec func(inte
 	return interceptor(ctx, in, info, handler)
 }
 
+func _GroupCache_BatchGet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GroupCacheServer).BatchGet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/cachepb.GroupCache/BatchGet",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GroupCacheServer).BatchGet(ctx, req.(*BatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GroupCache_GetStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Request)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(GroupCacheServer).GetStream(m, &groupCacheGetStreamServer{stream})
+}
+
+type GroupCache_GetStreamServer interface {
+	Send(*Chunk) error
+	grpc.ServerStream
+}
+
+type groupCacheGetStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *groupCacheGetStreamServer) Send(m *Chunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _GroupCache_Invalidate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InvalidateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GroupCacheServer).Invalidate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/cachepb.GroupCache/Invalidate",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GroupCacheServer).Invalidate(ctx, req.(*InvalidateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GroupCache_Put_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PutRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GroupCacheServer).Put(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/cachepb.GroupCache/Put",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GroupCacheServer).Put(ctx, req.(*PutRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GroupCache_CompareAndSet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CompareAndSetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GroupCacheServer).CompareAndSet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/cachepb.GroupCache/CompareAndSet",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GroupCacheServer).CompareAndSet(ctx, req.(*CompareAndSetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _GroupCache_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "cachepb.GroupCache",
 	HandlerType: (*GroupCacheServer)(nil),
@@ -206,7 +1023,29 @@ var _GroupCache_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Get",
 			Handler:    _GroupCache_Get_Handler,
 		},
+		{
+			MethodName: "BatchGet",
+			Handler:    _GroupCache_BatchGet_Handler,
+		},
+		{
+			MethodName: "Invalidate",
+			Handler:    _GroupCache_Invalidate_Handler,
+		},
+		{
+			MethodName: "Put",
+			Handler:    _GroupCache_Put_Handler,
+		},
+		{
+			MethodName: "CompareAndSet",
+			Handler:    _GroupCache_CompareAndSet_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "GetStream",
+			Handler:       _GroupCache_GetStream_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "cachepb.proto",
 }
@@ -0,0 +1,117 @@
+package dcache
+
+import (
+	"dcache/cachepb"
+	"errors"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+)
+
+/*
+版本与 CompareAndSet：
+
+每条记录放入缓存时分配一个新的版本，GetVersioned 同时返回值和版本。
+CompareAndSet 只在记录的版本与给定的版本一致时写入，写入后记录获得新的版本，
+用于 读取-修改-写入 时避免覆盖其他客户端的更新。
+Set 和 CompareAndSet 都发送给 key 所属的节点执行，在该节点上持有同一个写入锁，
+每次写入都会改变记录的版本，之前读到的版本都会冲突。
+版本不持久化，从快照或磁盘缓存恢复的记录获得新的版本。
+每个 Group 从创建时的时间开始分配版本，重启前读到的版本不会与重启后的记录相同。
+*/
+
+// ErrVersionConflict 记录的版本与 CompareAndSet 给定的版本不一致，或者记录已经不在缓存中
+var ErrVersionConflict = errors.New("dcache: version conflict")
+
+// PeerCompareAndSetter 支持 CompareAndSet 的远程节点
+type PeerCompareAndSetter interface {
+	PeerGetter
+	CompareAndSet(in *cachepb.CompareAndSetRequest, out *cachepb.CompareAndSetResponse) error
+}
+
+// casStripes 串行化写入的锁的个数，不同的key 尽量使用不同的锁
+const casStripes = 64
+
+// GetVersioned 获取 key 对应的值以及记录的版本
+func (g *Group) GetVersioned(key string) (ByteView, uint64, error) {
+	e, err := g.getEntry(key)
+	if err != nil {
+		return ByteView{}, 0, err
+	}
+	v, err := e.decode()
+	return v, e.version, err
+}
+
+// CompareAndSet 记录的版本等于 version 时写入新的值，返回写入后记录的版本，
+// 版本不一致时返回 ErrVersionConflict；写入后端存储的方式与 Set 相同
+func (g *Group) CompareAndSet(key string, value []byte, version uint64, tags ...string) (uint64, error) {
+	if key == "" {
+		return 0, errors.New("key is required ")
+	}
+	gen := g.Generation()
+	if peer, ok := g.ownerPeer(key, gen); ok {
+		version, err := g.compareAndSetPeer(peer, &cachepb.CompareAndSetRequest{
+			Group:      g.name,
+			Key:        key,
+			Value:      value,
+			Version:    version,
+			Tags:       tags,
+			Generation: gen,
+		})
+		g.dropLocally(key)
+		return version, err
+	}
+	return g.compareAndSet(key, value, version, tags)
+}
+
+// compareAndSetPeer 将 CompareAndSet 发送给 key 所属的节点
+func (g *Group) compareAndSetPeer(peer PeerGetter, req *cachepb.CompareAndSetRequest) (uint64, error) {
+	cas, ok := peer.(PeerCompareAndSetter)
+	if !ok {
+		return 0, errors.New("dcache: peer does not support compare-and-set")
+	}
+	resp := &cachepb.CompareAndSetResponse{}
+	if err := cas.CompareAndSet(req, resp); err != nil {
+		return 0, err
+	}
+	if resp.Conflict {
+		return 0, ErrVersionConflict
+	}
+	return resp.Version, nil
+}
+
+// compareAndSet 在本机执行 CompareAndSet
+func (g *Group) compareAndSet(key string, value []byte, version uint64, tags []string) (uint64, error) {
+	e, err := g.set(key, value, tags, func(cur entry, ok bool) error {
+		if !ok || cur.version != version {
+			return ErrVersionConflict
+		}
+		return nil
+	})
+	return e.version, err
+}
+
+// compareAndSetResponse 处理远程节点的 CompareAndSet 请求
+func (g *Group) compareAndSetResponse(req *cachepb.CompareAndSetRequest) (*cachepb.CompareAndSetResponse, error) {
+	g.observeGeneration(req.GetGeneration())
+	version, err := g.compareAndSet(req.GetKey(), req.GetValue(), req.GetVersion(), req.GetTags())
+	if err == ErrVersionConflict {
+		return &cachepb.CompareAndSetResponse{Conflict: true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cachepb.CompareAndSetResponse{Version: version}, nil
+}
+
+// writeLock 返回 key 对应的写入锁
+func (g *Group) writeLock(key string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &g.writeLocks[h.Sum32()%casStripes]
+}
+
+// nextVersion 分配一个新的版本
+func (g *Group) nextVersion() uint64 {
+	return atomic.AddUint64(&g.version, 1)
+}
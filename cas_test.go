@@ -0,0 +1,135 @@
+package dcache
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestGroup_CompareAndSet(t *testing.T) {
+	gc := NewRegistry().NewGroup("cas", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("0"), nil
+	}))
+	_, v1, err := gc.GetVersioned("k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2, err := gc.CompareAndSet("k", []byte("1"), v1)
+	if err != nil || v2 == v1 {
+		t.Fatalf("version = %d, err = %v", v2, err)
+	}
+	if _, err := gc.CompareAndSet("k", []byte("2"), v1); err != ErrVersionConflict {
+		t.Fatalf("err = %v, want ErrVersionConflict", err)
+	}
+	if v, version, _ := gc.GetVersioned("k"); v.String() != "1" || version != v2 {
+		t.Fatalf("v = %q, version = %d", v, version)
+	}
+	// Set 之后旧的版本失效
+	gc.Set("k", []byte("3"))
+	if _, err := gc.CompareAndSet("k", []byte("4"), v2); err != ErrVersionConflict {
+		t.Fatalf("err = %v, want ErrVersionConflict", err)
+	}
+	// 不在缓存中的记录
+	if _, err := gc.CompareAndSet("missing", []byte("1"), 0); err != ErrVersionConflict {
+		t.Fatalf("err = %v, want ErrVersionConflict", err)
+	}
+}
+
+func TestGroup_CompareAndSetRestart(t *testing.T) {
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return []byte("0"), nil
+	})
+	old := NewRegistry().NewGroup("cas-restart", 2<<10, getter)
+	_, v1, err := old.GetVersioned("k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 重启后新的 Group 分配的版本与之前的不同，之前读到的版本冲突
+	gc := NewRegistry().NewGroup("cas-restart", 2<<10, getter)
+	if _, v2, _ := gc.GetVersioned("k"); v2 == v1 {
+		t.Fatalf("version %d reused after restart", v2)
+	}
+	if _, err := gc.CompareAndSet("k", []byte("1"), v1); err != ErrVersionConflict {
+		t.Fatalf("err = %v, want ErrVersionConflict", err)
+	}
+}
+
+func TestGroup_CompareAndSetConcurrent(t *testing.T) {
+	gc := NewRegistry().NewGroup("cas-concurrent", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("0"), nil
+	}))
+	const workers, incs = 8, 50
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < incs; {
+				v, version, err := gc.GetVersioned("counter")
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				cur, _ := strconv.Atoi(v.String())
+				if _, err := gc.CompareAndSet("counter", []byte(strconv.Itoa(cur+1)), version); err == nil {
+					n++
+				} else if err != ErrVersionConflict {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	// 没有丢失的更新
+	if v, _ := gc.Get("counter"); v.String() != strconv.Itoa(workers*incs) {
+		t.Fatalf("counter = %s", v)
+	}
+}
+
+func TestGroup_CompareAndSetPeer(t *testing.T) {
+	nodes := []*invalidateNode{newInvalidateNode(), newInvalidateNode()}
+	var addrs []string
+	for _, n := range nodes {
+		defer n.srv.Close()
+		addrs = append(addrs, n.srv.URL)
+	}
+	for _, n := range nodes {
+		n.pool.Set(HttpGetter, addrs...)
+	}
+	// 找到一个属于 nodes[1] 的key
+	var key string
+	for i := 0; key == ""; i++ {
+		k := "key" + strconv.Itoa(i)
+		if _, ok := nodes[0].pool.PickPeer(k); ok {
+			key = k
+		}
+	}
+
+	// 版本由属主节点分配，CompareAndSet 发送给属主节点
+	_, version, err := nodes[0].group.GetVersioned(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2, err := nodes[0].group.CompareAndSet(key, []byte("new"), version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, got, _ := nodes[1].group.GetVersioned(key); v.String() != "new" || got != v2 {
+		t.Fatalf("owner value = %q, version = %d, want %d", v, got, v2)
+	}
+	if _, err := nodes[0].group.CompareAndSet(key, []byte("newer"), version); err != ErrVersionConflict {
+		t.Fatalf("err = %v, want ErrVersionConflict", err)
+	}
+
+	// 任意节点上的 Set 都改变属主节点上的版本
+	if err := nodes[0].group.Set(key, []byte("set")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := nodes[1].group.CompareAndSet(key, []byte("lost"), v2); err != ErrVersionConflict {
+		t.Fatalf("err = %v, want ErrVersionConflict", err)
+	}
+	if v, _ := nodes[0].group.Get(key); v.String() != "set" {
+		t.Fatalf("value = %q", v)
+	}
+}
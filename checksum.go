@@ -0,0 +1,34 @@
+package dcache
+
+import (
+	"errors"
+	"hash/crc32"
+)
+
+// ErrChecksumMismatch 值的校验和不一致，数据可能已经损坏
+var ErrChecksumMismatch = errors.New("dcache: checksum mismatch")
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// checksum 计算值的 CRC32C 校验和
+func checksum(v ByteView) uint32 {
+	var sum uint32
+	v.eachChunk(func(c []byte) bool {
+		sum = crc32.Update(sum, crc32c, c)
+		return true
+	})
+	return sum
+}
+
+// verify 校验远程节点返回的记录，不一致时计入统计
+func (g *Group) verify(e entry) error {
+	value, err := e.decode()
+	if err != nil {
+		return err
+	}
+	if checksum(value) != e.checksum {
+		g.Stats.ChecksumMismatches.Add(1)
+		return ErrChecksumMismatch
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package dcache
+
+import (
+	"dcache/cachepb"
+	"testing"
+)
+
+func TestChecksum(t *testing.T) {
+	data := []byte("hello world")
+	if checksum(ByteView{b: data}) != checksum(chunkedView(splitChunks(data, 3))) {
+		t.Fatal("checksum of chunked value differs")
+	}
+
+	gc := NewGroup("checksum", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}))
+	gc.Get("hello")
+	if e, _ := gc.mainCache.get("hello"); e.checksum != checksum(ByteView{b: []byte("hello")}) {
+		t.Fatal("checksum not stored")
+	}
+	if resp, _ := gc.response("hello", 0); resp.Checksum != checksum(ByteView{b: []byte("hello")}) {
+		t.Fatal("checksum not sent to peer")
+	}
+}
+
+func TestGroup_ChecksumMismatch(t *testing.T) {
+	loads := 0
+	gc := NewGroup("checksum-mismatch", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		loads++
+		return []byte("origin"), nil
+	}))
+	// 远程节点返回的值已经损坏
+	gc.RegisterPeers(&fakePicker{peer: &fakePeer{resp: cachepb.Response{
+		Value:    []byte("corrupted"),
+		Checksum: checksum(ByteView{b: []byte("original")}),
+	}}})
+
+	v, err := gc.Get("k")
+	if err != nil || v.String() != "origin" || loads != 1 {
+		t.Fatalf("should fall back to origin, got %s, %v", v, err)
+	}
+	if gc.Stats.ChecksumMismatches.Get() != 1 {
+		t.Fatal("mismatch not counted")
+	}
+}
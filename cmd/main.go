@@ -58,7 +58,7 @@ func startAPIServer(addr string, dc *dcache.Group) {
 			return
 		}
 		w.Header().Set("Content-Type", "application/octet-stream")
-		w.Write(view.ByteSlice())
+		view.WriteTo(w)
 	}))
 	log.Println("frontend server is running at ", addr)
 	log.Fatalln(http.ListenAndServe(addr[7:], nil))
@@ -0,0 +1,99 @@
+package dcache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"dcache/cachepb"
+	"fmt"
+	"io/ioutil"
+	"sync"
+)
+
+// Compressor 压缩算法，开启压缩后缓存和节点间传输的都是压缩后的数据
+type Compressor interface {
+	// Type 压缩算法的类型，会随数据一起传给远程节点
+	Type() cachepb.Compression
+	Compress(b []byte) ([]byte, error)
+	Decompress(b []byte) ([]byte, error)
+}
+
+// Gzip 使用标准库的 gzip，默认已经注册
+var Gzip Compressor = gzipCompressor{}
+
+var (
+	compressorsMu sync.RWMutex
+	compressors   = map[cachepb.Compression]Compressor{cachepb.Compression_GZIP: Gzip}
+)
+
+// RegisterCompressor 注册压缩算法，同一类型后注册的覆盖之前的，
+// 用于接入 snappy 等标准库以外的算法，集群中的所有节点都需要注册
+func RegisterCompressor(c Compressor) {
+	compressorsMu.Lock()
+	defer compressorsMu.Unlock()
+	compressors[c.Type()] = c
+}
+
+// compressorFor 根据类型获取压缩算法
+func compressorFor(t cachepb.Compression) (Compressor, error) {
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+	if c, ok := compressors[t]; ok {
+		return c, nil
+	}
+	return nil, fmt.Errorf("dcache: unknown compression %v", t)
+}
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) Type() cachepb.Compression {
+	return cachepb.Compression_GZIP
+}
+
+func (gzipCompressor) Compress(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCompressor) Decompress(b []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// compress 压缩超过阈值的数据，压缩后没有变小时返回原始数据（不会拷贝）
+func (g *Group) compress(b []byte) ([]byte, cachepb.Compression) {
+	if g.compressor == nil || len(b) < g.compressThreshold {
+		return b, cachepb.Compression_NONE
+	}
+	c, err := g.compressor.Compress(b)
+	if err != nil || len(c) >= len(b) {
+		return b, cachepb.Compression_NONE
+	}
+	return c, g.compressor.Type()
+}
+
+// decode 返回记录解压后的值
+func (e entry) decode() (ByteView, error) {
+	if e.compression == cachepb.Compression_NONE {
+		return e.value, nil
+	}
+	c, err := compressorFor(e.compression)
+	if err != nil {
+		return ByteView{}, err
+	}
+	b, err := c.Decompress(e.value.bytes())
+	if err != nil {
+		return ByteView{}, fmt.Errorf("dcache: decompress: %v", err)
+	}
+	return ByteView{b: b}, nil
+}
@@ -0,0 +1,114 @@
+package dcache
+
+import (
+	"bytes"
+	"compress/flate"
+	"dcache/cachepb"
+	"io/ioutil"
+	"testing"
+)
+
+// flateCompressor 代替 snappy 等需要注册的压缩算法
+type flateCompressor struct{}
+
+func (flateCompressor) Type() cachepb.Compression {
+	return cachepb.Compression_SNAPPY
+}
+
+func (flateCompressor) Compress(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, _ := flate.NewWriter(&buf, flate.BestSpeed)
+	w.Write(b)
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (flateCompressor) Decompress(b []byte) ([]byte, error) {
+	return ioutil.ReadAll(flate.NewReader(bytes.NewReader(b)))
+}
+
+func TestCompressor(t *testing.T) {
+	data := bytes.Repeat([]byte("dcache "), 100)
+	for _, c := range []Compressor{Gzip, flateCompressor{}} {
+		b, err := c.Compress(data)
+		if err != nil || len(b) >= len(data) {
+			t.Fatalf("%v: compress failed", c.Type())
+		}
+		got, err := c.Decompress(b)
+		if err != nil || !bytes.Equal(got, data) {
+			t.Fatalf("%v: decompress failed", c.Type())
+		}
+	}
+}
+
+func TestGroup_Compression(t *testing.T) {
+	large := bytes.Repeat([]byte("dcache "), 100)
+	gc := NewGroup("compression", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		if key == "large" {
+			return large, nil
+		}
+		return []byte(key), nil
+	}), WithCompression(flateCompressor{}, 64))
+
+	if v, err := gc.Get("large"); err != nil || !v.EqualBytes(large) {
+		t.Fatal("get large value failed")
+	}
+	e, _ := gc.mainCache.get("large")
+	if e.compression != cachepb.Compression_SNAPPY || e.value.Len() >= len(large) {
+		t.Fatal("large value should be stored compressed")
+	}
+	// 按压缩后的大小计算内存
+	if n := gc.mainCache.lru.Bytes(); n != int64(len("large")+e.value.Len())+entryOverhead {
+		t.Fatalf("cache bytes = %d", n)
+	}
+
+	// 小于阈值的值不压缩
+	gc.Get("small")
+	if e, _ := gc.mainCache.get("small"); e.compression != cachepb.Compression_NONE {
+		t.Fatal("small value should not be compressed")
+	}
+
+	// 发给远程节点的是压缩后的数据
+	resp, err := gc.response("large", 0)
+	if err != nil || resp.Compression != cachepb.Compression_SNAPPY || len(resp.Value) >= len(large) {
+		t.Fatal("response should carry compressed value")
+	}
+}
+
+func TestGroup_CompressedFromPeer(t *testing.T) {
+	data := bytes.Repeat([]byte("dcache "), 100)
+	compressed, _ := Gzip.Compress(data)
+	gc := NewGroup("compressed-peer", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return nil, ErrNotFound
+	}))
+	gc.RegisterPeers(&fakePicker{peer: &fakePeer{resp: cachepb.Response{
+		Value:       compressed,
+		Compression: cachepb.Compression_GZIP,
+		Checksum:    checksum(ByteView{b: data}),
+	}}})
+
+	if v, err := gc.Get("k"); err != nil || !v.EqualBytes(data) {
+		t.Fatalf("get compressed value from peer failed: %v", err)
+	}
+}
+
+func TestRegisterCompressor(t *testing.T) {
+	data := bytes.Repeat([]byte("dcache "), 100)
+	compressed, _ := flateCompressor{}.Compress(data)
+	gc := NewRegistry().NewGroup("compressor-registered", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return nil, ErrNotFound
+	}))
+	gc.RegisterPeers(&fakePicker{peer: &fakePeer{resp: cachepb.Response{
+		Value:       compressed,
+		Compression: cachepb.Compression_SNAPPY,
+		Checksum:    checksum(ByteView{b: data}),
+	}}})
+
+	// 注册后可以解压远程节点使用该算法压缩的值
+	RegisterCompressor(flateCompressor{})
+	if v, err := gc.Get("k"); err != nil || !v.EqualBytes(data) {
+		t.Fatalf("get value compressed by registered compressor failed: %v", err)
+	}
+}
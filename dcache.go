@@ -2,10 +2,15 @@ package dcache
 
 import (
 	"dcache/cachepb"
+	"dcache/disk"
+	"dcache/lru"
 	"dcache/singleflight"
 	"errors"
+	"fmt"
 	"log"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 /*
@@ -30,55 +35,190 @@ func (f GetterFunc) Get(key string) ([]byte, error) {
 	return f(key)
 }
 
+// ErrNotFound 源数据中不存在该key
+// Getter 可以返回该错误（或包装了该错误的错误），开启负缓存后，不存在的key会被缓存一段时间
+var ErrNotFound = errors.New("dcache: not found")
+
+// IsNotFound 判断错误是否表示key不存在
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
 // Group 缓存的命名空间
 type Group struct {
-	name      string // 缓存的名字
-	getter    Getter // 获取数据的回调函数
-	mainCache cache  // 缓存
-	pickers   PeerPicker
+	name      string       // 缓存的名字
+	getter    Getter       // 获取数据的回调函数
+	mainCache cache        // 缓存
+	pickers   atomic.Value // 该 Group 自己注册的节点，类型为 pickerValue，可以与请求并发设置
+	Stats     Stats        // 统计信息
+	registry  *Registry    // 所属的 Registry
+
+	weight      float64 // 共享内存时的权重
+	recentHits  float64 // 最近的命中次数，由 Registry 采样
+	sampledHits int64   // 上次采样时的命中次数
 
 	loader *singleflight.Group
+	now    func() time.Time // 时钟，用于计算过期时间
+
+	evictionHooks []EvictionHook // 记录被移除时的回调
+
+	negCache    *cache        // 负缓存，记录源数据中不存在的key，未开启时为 nil
+	negativeTTL time.Duration // 负缓存的有效期
+
+	ttl                  time.Duration       // 记录保持新鲜的时间，为0时永不过期
+	refreshAhead         float64             // 剩余新鲜时间低于 ttl 的该比例时，在后台提前刷新
+	staleWhileRevalidate time.Duration       // 过期后的该时间内返回旧值，同时在后台刷新
+	staleIfError         time.Duration       // 过期后的该时间内，加载失败时返回旧值
+	refresher            *singleflight.Group // 合并后台刷新
+
+	compressor        Compressor // 压缩算法，未开启时为 nil
+	compressThreshold int        // 不小于该大小的值才压缩
+
+	chunkThreshold int // 超过该大小的值分块存储
+	chunkSize      int // 每一块的大小，为0时不分块
+
+	disk   *disk.Store // 二级磁盘缓存，未开启时为 nil
+	spills *spillQueue // 等待写入磁盘缓存的记录
+	tags   tagIndex    // 标签到key 的索引
+	gen    uint64      // 当前的代，只能原子地访问
+	leases leaseTable  // 正在加载的key 的租约
+
+	version    uint64                 // 最近分配的记录版本，只能原子地访问
+	writeLocks [casStripes]sync.Mutex // 串行化同一个key 的 Set 和 CompareAndSet
+
+	setter Setter       // write-through 时同步写入的后端存储
+	writer *writeBehind // write-behind 队列，未开启时为 nil
+
+	snapshotPath     string        // 快照文件，为空时不保存快照
+	snapshotInterval time.Duration // 定期保存快照的间隔，为0时只在关闭时保存
+	snapshotStop     chan struct{}
+	snapshotDone     chan struct{}
 }
 
-var (
-	mu     sync.RWMutex              // 一个读写锁
-	groups = make(map[string]*Group) // 全局缓存所有的Group
-)
+// NewGroup新建一个新的Group，然后放入默认的 Registry 中
+// 已经存在同名的 Group 时会覆盖，需要报错时使用 CreateGroup
+func NewGroup(name string, cacheBytes int64, getter Getter, opts ...GroupOption) *Group {
+	return DefaultRegistry.NewGroup(name, cacheBytes, getter, opts...)
+}
+
+// CreateGroup 与 NewGroup 相同，但同名的 Group 已经存在时返回 ErrGroupExists
+func CreateGroup(name string, cacheBytes int64, getter Getter, opts ...GroupOption) (*Group, error) {
+	return DefaultRegistry.CreateGroup(name, cacheBytes, getter, opts...)
+}
 
-// NewGroup新建一个新的Group，然后放入全局缓存中
-func NewGroup(name string, cacheBytes int64, getter Getter) *Group {
+// GetGroup 从默认的 Registry 中获取一个指定的Group
+func GetGroup(name string) *Group {
+	return DefaultRegistry.GetGroup(name)
+}
+
+// DeleteGroup 从默认的 Registry 中删除指定的Group
+func DeleteGroup(name string) bool {
+	return DefaultRegistry.DeleteGroup(name)
+}
+
+// ListGroups 按名字排序返回默认的 Registry 中所有的Group
+func ListGroups() []*Group {
+	return DefaultRegistry.ListGroups()
+}
+
+// RegisterNewGroupHook 注册默认的 Registry 创建 Group 时的回调
+func RegisterNewGroupHook(fn func(*Group)) {
+	DefaultRegistry.RegisterNewGroupHook(fn)
+}
+
+// newGroup 创建 Group，不会放入 Registry，放入 Registry 后再调用 start
+func newGroup(name string, cacheBytes int64, getter Getter, opts ...GroupOption) *Group {
 	if getter == nil {
 		// 获取源数据的回调函数不能为空
 		panic("nil getter")
 	}
 
-	mu.Lock()
-	defer mu.Unlock()
-
 	g := &Group{
 		name:      name,
 		getter:    getter,
 		mainCache: cache{cacheBytes: cacheBytes},
 		loader:    &singleflight.Group{},
+		now:       time.Now,
+		refresher: &singleflight.Group{},
+		weight:    1,
+		// 从当前时间开始分配版本，重启后不会重复使用之前的版本
+		version: uint64(time.Now().UnixNano()),
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	g.mainCache.onEvicted = g.evicted
+	if len(g.evictionHooks) > 0 {
+		g.mainCache.onRemoved = g.runEvictionHooks
+	}
+	g.mainCache.now = g.now
+	if g.negCache != nil {
+		g.negCache.now = g.now
 	}
-	groups[name] = g
 	return g
 }
 
-// GetGroup 获取一个指定的Group
-func GetGroup(name string) *Group {
-	mu.RLock()
-	g := groups[name]
-	mu.RUnlock()
-	return g
+// start 启动 write-behind 和定期快照等后台任务，开启快照时先从快照恢复
+func (g *Group) start() {
+	if g.writer != nil {
+		g.writer.start()
+	}
+	if g.snapshotPath != "" {
+		g.startSnapshots()
+	}
+}
+
+// close 清空 Group 的缓存，开启了 write-behind 时先写入剩余的数据，开启了快照时先保存快照，
+// 开启了磁盘缓存时先写入等待写入磁盘的记录
+func (g *Group) close() {
+	if g.writer != nil {
+		if err := g.writer.close(); err != nil {
+			log.Println("[cache] Failed to flush writes", err)
+		}
+	}
+	if g.snapshotPath != "" {
+		g.stopSnapshots()
+	}
+	if g.spills != nil {
+		g.spills.flush()
+	}
+	g.mainCache.purge()
+	g.mainCache.track(nil)
+	if g.negCache != nil {
+		g.negCache.purge()
+	}
+}
+
+// Name 返回Group的名字
+func (g *Group) Name() string {
+	return g.name
+}
+
+// CacheBytes 返回缓存允许使用的最大内存
+func (g *Group) CacheBytes() int64 {
+	return g.mainCache.maxBytes()
+}
+
+// SetCacheBytes 修改缓存允许使用的最大内存，缩小时会立即淘汰多余的记录
+func (g *Group) SetCacheBytes(cacheBytes int64) {
+	g.mainCache.resize(cacheBytes)
 }
 
 // Get 从缓存中获取数据
 func (g *Group) Get(key string) (ByteView, error) {
+	e, err := g.getEntry(key)
+	if err != nil {
+		return ByteView{}, err
+	}
+	return e.decode()
+}
+
+// getEntry 获取key对应的记录，记录中的值可能是压缩后的数据
+func (g *Group) getEntry(key string) (entry, error) {
 	if key == "" {
-		return ByteView{}, errors.New("key is required ")
+		return entry{}, errors.New("key is required ")
 	}
-	value, ok := g.mainCache.get(key)
+	e, ok := g.mainCache.getGen(key, g.Generation())
 	//if !ok {
 	//	b, err := g.getter.Get(key)
 	//	if err != nil {
@@ -89,22 +229,46 @@ func (g *Group) Get(key string) (ByteView, error) {
 	//}
 
 	if ok {
-		log.Println("[Cache] hit")
-		return value, nil
+		if g.serveCached(key, e) {
+			log.Println("[Cache] hit")
+			g.Stats.CacheHits.Add(1)
+			return e, nil
+		}
+		// 记录已过期，重新加载，失败时在 staleIfError 内返回旧值
+		loaded, err := g.load(key)
+		if err != nil && g.now().Before(e.fresh.Add(g.staleIfError)) {
+			log.Println("[Cache] serve stale value on error:", err)
+			return e, nil
+		}
+		return loaded, err
+	}
+	if g.isNegative(key) {
+		return entry{}, ErrNotFound
 	}
 	return g.load(key)
 }
 
 // load 加载数据 分别从本地，和远程加载数据
-func (g *Group) load(key string) (ByteView, error) {
-	// 增加保护机制
-	b, err := g.loader.Do(key, func() (i interface{}, err error) {
+func (g *Group) load(key string) (entry, error) {
+	// 增加保护机制，不同代的请求不合并
+	gen := g.Generation()
+	e, err := g.loader.Do(genKey(key, gen), func() (i interface{}, err error) {
+		// 先查询磁盘缓存
+		if e, ok := g.getFromDisk(key); ok {
+			return e, nil
+		}
 		// 如果没有注册peer，还是调用本地缓存
 
-		if g.pickers != nil {
-			if peer, ok := g.pickers.PickPeer(key); ok {
-				if value, err := g.getFromPeer(peer, key); err == nil {
-					return value, err
+		if pickers := g.peerPicker(); pickers != nil {
+			if peer, ok := pickers.PickPeer(genKey(key, gen)); ok {
+				e, err := g.getFromPeerWait(peer, key)
+				if err == nil {
+					return e, err
+				}
+				if IsNotFound(err) {
+					// 远程节点确认key不存在，不再从本地加载
+					g.addNegative(key)
+					return nil, err
 				}
 				log.Println("[cache] Failed to get from peer")
 			}
@@ -113,38 +277,278 @@ func (g *Group) load(key string) (ByteView, error) {
 	})
 
 	if err != nil {
-		return ByteView{}, err
+		return entry{}, err
 	}
-	return b.(ByteView), nil
+	return e.(entry), nil
 }
 
-func (g *Group) getFromPeer(getter PeerGetter, key string) (ByteView, error) {
+func (g *Group) getFromPeer(getter PeerGetter, key string) (entry, error) {
 	//bytes, err := getter.Get(g.name, key)
 	//if err != nil {
 	//	return ByteView{}, err
 	//}
+	req := &cachepb.Request{
+		Group:      g.name,
+		Key:        key,
+		Generation: g.Generation(),
+	}
+	stream, canStream := getter.(StreamPeerGetter)
+	if canStream {
+		req.MaxSize = uint64(g.streamThreshold())
+	}
 	resp := &cachepb.Response{}
-	err := getter.Get(&cachepb.Request{
-		Group: g.name,
-		Key:   key,
-	}, resp)
+	err := getter.Get(req, resp)
 	if err != nil {
-		return ByteView{}, err
+		return entry{}, err
+	}
+	e, err := responseEntry(resp)
+	if err != nil {
+		return entry{}, err
+	}
+	if canStream && resp.Size > uint64(len(resp.Value)) {
+		// 大的值分块传输，不需要一次性读入连续的内存
+		header, chunks, err := stream.GetStream(req)
+		if err != nil {
+			return entry{}, err
+		}
+		if e, err = responseEntry(header); err != nil {
+			return entry{}, err
+		}
+		e.value = chunkedView(chunks)
+	}
+	return e, g.verifyFromPeer(key, e)
+}
+
+// verifyFromPeer 校验远程节点返回的记录，不一致时返回错误，由调用方从源数据重新加载
+func (g *Group) verifyFromPeer(key string, e entry) error {
+	if err := g.verify(e); err != nil {
+		log.Println("[cache] Invalid value from peer", key, err)
+		return err
+	}
+	return nil
+}
+
+// responseEntry 将远程节点的响应转换为记录
+func responseEntry(resp *cachepb.Response) (entry, error) {
+	if resp.NotFound {
+		return entry{}, ErrNotFound
+	}
+	if resp.LeaseHeld {
+		return entry{}, errLeaseHeld
+	}
+	if resp.Error != "" {
+		return entry{}, errors.New("dcache: peer failed: " + resp.Error)
+	}
+	return entry{value: ByteView{b: resp.Value}, compression: resp.Compression, checksum: resp.Checksum, version: resp.Version}, nil
+}
+
+// streamThreshold 超过该大小的值从远程节点分块传输
+func (g *Group) streamThreshold() int {
+	if g.chunkSize > 0 {
+		return g.chunkThreshold
+	}
+	return streamChunkSize
+}
+
+// response 处理远程节点的请求，key不存在时通过 NotFound 告知远程节点，
+// maxSize 不为0时，超过该大小的值只返回 Size，由远程节点改用分块传输
+func (g *Group) response(key string, maxSize uint64) (*cachepb.Response, error) {
+	e, err := g.peerEntry(key)
+	if IsNotFound(err) {
+		return &cachepb.Response{NotFound: true}, nil
+	}
+	if err == errLeaseHeld {
+		return &cachepb.Response{LeaseHeld: true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if maxSize > 0 && uint64(e.value.Len()) > maxSize {
+		return entryHeader(e), nil
 	}
-	return ByteView{b: resp.Value}, nil
+	return entryResponse(e), nil
+}
+
+// entryResponse 将记录转换为响应，压缩后的数据直接发送
+func entryResponse(e entry) *cachepb.Response {
+	resp := entryHeader(e)
+	resp.Value = e.value.ByteSlice()
+	return resp
+}
+
+// entryHeader 除 value 外的响应信息
+func entryHeader(e entry) *cachepb.Response {
+	return &cachepb.Response{Compression: e.compression, Checksum: e.checksum, Version: e.version, Size: uint64(e.value.Len())}
+}
+
+// streamResponse 处理远程节点的分块传输请求，返回响应信息和需要分块发送的值
+func (g *Group) streamResponse(key string) (*cachepb.Response, ByteView, error) {
+	e, err := g.peerEntry(key)
+	if IsNotFound(err) {
+		return &cachepb.Response{NotFound: true}, ByteView{}, nil
+	}
+	if err == errLeaseHeld {
+		return &cachepb.Response{LeaseHeld: true}, ByteView{}, nil
+	}
+	if err != nil {
+		return nil, ByteView{}, err
+	}
+	return entryHeader(e), e.value, nil
 }
 
 // getLocally 从本地获取数据
-func (g *Group) getLocally(key string) (ByteView, error) {
-	b, err := g.getter.Get(key)
+func (g *Group) getLocally(key string) (entry, error) {
+	// 加载期间代增加时，加载到的记录属于旧代
+	gen := g.Generation()
+	// 加载期间 key 被删除或写入时租约作废，加载到的值不再放入缓存
+	lease := g.leases.acquire(key)
+	// 还没有写入后端存储的值
+	if g.writer != nil {
+		if p, ok := g.writer.lookup(key); ok {
+			return g.populate(key, p.value, p.tags, 0, gen, lease), nil
+		}
+	}
+	start := time.Now()
+	var (
+		b    []byte
+		tags []string
+		err  error
+	)
+	if tg, ok := g.getter.(TagGetter); ok {
+		b, tags, err = tg.GetWithTags(key)
+	} else {
+		b, err = g.getter.Get(key)
+	}
 	if err != nil {
-		return ByteView{}, err
+		g.leases.commit(key, lease, func() {
+			if IsNotFound(err) {
+				g.addNegative(key)
+			}
+		})
+		return entry{}, err
+	}
+	// 记录加载耗时，作为淘汰时的参考
+	return g.populate(key, b, tags, time.Since(start), gen, lease), nil
+}
+
+// populate 缓存从源数据中获取的数据，tags 为记录的标签，cost 为加载耗时，gen 为开始加载时的代，
+// lease 为加载前获取的租约，租约已作废时只返回记录，不放入缓存；为0时不检查租约
+func (g *Group) populate(key string, b []byte, tags []string, cost time.Duration, gen, lease uint64) entry {
+	// 超过阈值时压缩
+	data, compression := g.compress(b)
+	// 拷贝原始数据，大的值分块存储
+	var value ByteView
+	switch {
+	case g.chunkSize > 0 && len(data) > g.chunkThreshold:
+		value = chunkedView(splitChunks(data, g.chunkSize))
+	case compression == cachepb.Compression_NONE:
+		value = ByteView{b: cloeBytes(data)}
+	default:
+		value = ByteView{b: data}
+	}
+	e := entry{value: value, compression: compression, checksum: checksum(ByteView{b: b}), cost: cost, tags: tags, gen: gen, version: g.nextVersion()}
+	if g.ttl > 0 {
+		e.fresh = g.now().Add(g.ttl)
+	}
+	add := func() {
+		g.mainCache.addWithExpire(key, e, g.expireAt(e.fresh))
+		g.tags.set(key, tags)
+	}
+	if lease == 0 {
+		add()
+	} else if !g.leases.commitTags(key, lease, tags, add) {
+		log.Println("[cache] Drop stale value", key)
+		g.Stats.StaleSets.Add(1)
+		return e
+	}
+	if g.registry != nil {
+		g.registry.enforceBudget()
+	}
+	return e
+}
+
+// expireAt 根据记录的新鲜时间计算过期时间，过期后仍保留一段时间，用于返回旧值
+func (g *Group) expireAt(fresh time.Time) time.Time {
+	if fresh.IsZero() {
+		return time.Time{}
+	}
+	stale := g.staleWhileRevalidate
+	if g.staleIfError > stale {
+		stale = g.staleIfError
+	}
+	return fresh.Add(stale)
+}
+
+// serveCached 判断缓存的记录能否直接返回
+// 新鲜的记录直接返回，接近过期时在后台提前刷新；
+// 过期不久（staleWhileRevalidate 内）的记录也直接返回，同时在后台刷新
+func (g *Group) serveCached(key string, e entry) bool {
+	if e.fresh.IsZero() {
+		return true
+	}
+	now := g.now()
+	if remain := e.fresh.Sub(now); remain > 0 {
+		if g.refreshAhead > 0 && float64(remain) <= g.refreshAhead*float64(g.ttl) {
+			g.refresh(key)
+		}
+		return true
+	}
+	if now.Before(e.fresh.Add(g.staleWhileRevalidate)) {
+		g.refresh(key)
+		return true
+	}
+	return false
+}
+
+// refresh 在后台重新从源数据加载key，同一个key同时只会有一个刷新，
+// 刷新进行中时直接返回，不会为每次命中创建 goroutine
+func (g *Group) refresh(key string) {
+	g.refresher.Go(key, func() (interface{}, error) {
+		e, err := g.getLocally(key)
+		if err != nil {
+			log.Println("[cache] Failed to refresh", key, err)
+		}
+		return e, err
+	})
+}
+
+// isNegative 判断key是否在负缓存中
+func (g *Group) isNegative(key string) bool {
+	if g.negCache == nil {
+		return false
+	}
+	_, ok := g.negCache.getGen(key, g.Generation())
+	return ok
+}
+
+// addNegative 将不存在的key加入负缓存
+func (g *Group) addNegative(key string) {
+	if g.negCache == nil {
+		return
+	}
+	g.negCache.addWithExpire(key, entry{gen: g.Generation()}, g.now().Add(g.negativeTTL))
+}
+
+// evicted 缓存移除记录时更新标签索引，容量淘汰的记录写入磁盘缓存，持有缓存的锁
+func (g *Group) evicted(key string, e entry, reason lru.EvictReason) {
+	switch {
+	case g.disk != nil && reason == lru.EvictCapacity && g.spill(key, e):
+		// 写入磁盘缓存后仍保留标签
+	case reason != lru.EvictReplaced:
+		g.tags.remove(key)
+	}
+}
+
+// runEvictionHooks 调用 EvictionHook，在释放缓存的锁之后调用，解压不会阻塞其他请求
+func (g *Group) runEvictionHooks(key string, e entry, reason lru.EvictReason) {
+	value, err := e.decode()
+	if err != nil {
+		log.Println("[cache] Failed to decode evicted value", key, err)
+		return
+	}
+	for _, hook := range g.evictionHooks {
+		hook(key, value, reason)
 	}
-	// 拷贝原始数据
-	value := ByteView{b: cloeBytes(b)}
-	// 缓存从源数据中获取的数据
-	g.mainCache.add(key, value)
-	return value, nil
 }
 
 func cloeBytes(b []byte) []byte {
@@ -153,9 +557,25 @@ func cloeBytes(b []byte) []byte {
 	return c
 }
 
+// RegisterPeers 设置该 Group 使用的节点，优先于 Registry 的节点，只能调用一次
 func (g *Group) RegisterPeers(picker PeerPicker) {
-	if g.pickers != nil {
-		panic("RegisterPeerPicker called more than once")
+	if !g.pickers.CompareAndSwap(nil, pickerValue{picker}) {
+		panic(fmt.Sprintf("dcache: RegisterPeers called more than once for group %q", g.name))
+	}
+}
+
+// pickerValue 使 atomic.Value 中保存的类型一致
+type pickerValue struct {
+	PeerPicker
+}
+
+// peerPicker 返回 Group 使用的节点，没有注册时使用 Registry 的节点，都没有时返回 nil
+func (g *Group) peerPicker() PeerPicker {
+	if p, ok := g.pickers.Load().(pickerValue); ok {
+		return p.PeerPicker
+	}
+	if g.registry != nil {
+		return g.registry.peerPicker()
 	}
-	g.pickers = picker
+	return nil
 }
@@ -1,10 +1,17 @@
 package dcache
 
 import (
+	"dcache/cachepb"
+	"dcache/lru"
 	"fmt"
 	"log"
 	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestGetterFunc_Get(t *testing.T) {
@@ -49,3 +56,333 @@ func TestGroup_Get(t *testing.T) {
 		}
 	}
 }
+
+func TestGroup_EvictionHook(t *testing.T) {
+	evictions := make(map[lru.EvictReason][]string)
+	var gc *Group
+	gc = NewGroup("evictions", int64(len("Tom630Jack589"))+2*entryOverhead, GetterFunc(func(key string) ([]byte, error) {
+		if v, ok := db[key]; ok {
+			return []byte(v), nil
+		}
+		return nil, fmt.Errorf("%s not exist", key)
+	}), WithEvictionHook(func(key string, value ByteView, reason lru.EvictReason) {
+		evictions[reason] = append(evictions[reason], key)
+		// 回调中可以访问该 Group
+		if _, ok := gc.mainCache.get(key); ok {
+			t.Errorf("%s should be removed before the hook", key)
+		}
+	}))
+
+	for _, k := range []string{"Tom", "Jack", "Sam"} {
+		if _, err := gc.Get(k); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if keys := evictions[lru.EvictCapacity]; !reflect.DeepEqual(keys, []string{"Tom"}) {
+		t.Fatalf("capacity evictions = %v, want [Tom]", keys)
+	}
+}
+
+func TestGroup_CostAwareEviction(t *testing.T) {
+	gc := NewRegistry().NewGroup("cost", 3*(int64(len("k1v1"))+entryOverhead), GetterFunc(func(key string) ([]byte, error) {
+		return []byte("v" + key[1:]), nil
+	}), WithCostAwareEviction())
+
+	// k0 的加载代价高
+	gc.populate("k0", []byte("v0"), nil, 10*time.Millisecond, 0, 0)
+	for _, k := range []string{"k1", "k2", "k3", "k4"} {
+		gc.populate(k, []byte("v"+k[1:]), nil, time.Microsecond, 0, 0)
+	}
+	if _, ok := gc.mainCache.get("k0"); !ok {
+		t.Fatal("expensive key k0 should be kept")
+	}
+}
+
+// withClock 替换 Group 使用的时钟
+func withClock(now func() time.Time) GroupOption {
+	return func(g *Group) {
+		g.now = now
+	}
+}
+
+// fakeClock 可以手动推进的时钟，可以在多个 goroutine 中使用
+type fakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+func TestGroup_NegativeCache(t *testing.T) {
+	now := time.Unix(1000, 0)
+	loads := 0
+	gc := NewGroup("negative", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		loads++
+		return nil, fmt.Errorf("%s: %w", key, ErrNotFound)
+	}), WithNegativeCache(time.Second, 1<<10), withClock(func() time.Time { return now }))
+
+	for i := 0; i < 3; i++ {
+		if _, err := gc.Get("missing"); !IsNotFound(err) {
+			t.Fatalf("err = %v, want not found", err)
+		}
+	}
+	if loads != 1 {
+		t.Fatalf("getter called %d times, want 1", loads)
+	}
+
+	// 负缓存过期后重新调用 Getter
+	now = now.Add(time.Second)
+	if _, err := gc.Get("missing"); !IsNotFound(err) || loads != 2 {
+		t.Fatal("negative cache should expire")
+	}
+}
+
+type fakePeer struct {
+	resp  cachepb.Response
+	calls int
+}
+
+func (p *fakePeer) Get(in *cachepb.Request, out *cachepb.Response) error {
+	p.calls++
+	*out = p.resp
+	return nil
+}
+
+type fakePicker struct {
+	peer PeerGetter
+}
+
+func (p *fakePicker) PickPeer(key string) (PeerGetter, bool) {
+	return p.peer, p.peer != nil
+}
+
+func TestGroup_NegativeCacheFromPeer(t *testing.T) {
+	loads := 0
+	gc := NewGroup("negative-peer", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		loads++
+		return []byte("local"), nil
+	}), WithNegativeCache(time.Minute, 1<<10))
+	peer := &fakePeer{resp: cachepb.Response{NotFound: true}}
+	gc.RegisterPeers(&fakePicker{peer: peer})
+
+	for i := 0; i < 2; i++ {
+		if _, err := gc.Get("missing"); !IsNotFound(err) {
+			t.Fatalf("err = %v, want not found", err)
+		}
+	}
+	if peer.calls != 1 || loads != 0 {
+		t.Fatalf("peer calls = %d, loads = %d", peer.calls, loads)
+	}
+
+	resp, err := gc.response("missing", 0)
+	if err != nil || !resp.NotFound {
+		t.Fatal("response should report not found")
+	}
+}
+
+// versionedGetter 每次加载返回递增的版本号，fail 为 true 时返回错误
+type versionedGetter struct {
+	loads int32
+	fail  int32
+}
+
+func (v *versionedGetter) Get(key string) ([]byte, error) {
+	if atomic.LoadInt32(&v.fail) == 1 {
+		return nil, fmt.Errorf("origin down")
+	}
+	n := atomic.AddInt32(&v.loads, 1)
+	return []byte(fmt.Sprintf("%s-v%d", key, n)), nil
+}
+
+// waitValue 等待后台刷新后 key 的值变为 want
+func waitValue(t *testing.T, g *Group, key, want string) {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if e, ok := g.mainCache.get(key); ok && e.value.String() == want {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("%s never refreshed to %s", key, want)
+}
+
+func TestGroup_TTL(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	getter := &versionedGetter{}
+	gc := NewGroup("ttl", 2<<10, getter, WithTTL(time.Minute), withClock(clock.Now))
+
+	if v, _ := gc.Get("k"); v.String() != "k-v1" {
+		t.Fatalf("got %s", v)
+	}
+	clock.Advance(59 * time.Second)
+	if v, _ := gc.Get("k"); v.String() != "k-v1" {
+		t.Fatalf("got %s, want cached value", v)
+	}
+	clock.Advance(time.Second)
+	if v, _ := gc.Get("k"); v.String() != "k-v2" {
+		t.Fatalf("got %s, want reloaded value", v)
+	}
+}
+
+func TestGroup_RefreshAhead(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	getter := &versionedGetter{}
+	gc := NewGroup("refresh-ahead", 2<<10, getter,
+		WithTTL(time.Minute), WithRefreshAhead(0.2), withClock(clock.Now))
+
+	gc.Get("k")
+	clock.Advance(30 * time.Second)
+	gc.Get("k")
+	if n := atomic.LoadInt32(&getter.loads); n != 1 {
+		t.Fatalf("loads = %d, refreshed too early", n)
+	}
+
+	// 剩余 10s，低于 ttl 的 20%，返回当前值并在后台刷新
+	clock.Advance(20 * time.Second)
+	if v, _ := gc.Get("k"); v.String() != "k-v1" {
+		t.Fatalf("got %s, want current value", v)
+	}
+	waitValue(t, gc, "k", "k-v2")
+}
+
+func TestGroup_RefreshInFlight(t *testing.T) {
+	release := make(chan struct{})
+	var loads int32
+	gc := NewRegistry().NewGroup("refresh-in-flight", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		return []byte(key), nil
+	}))
+
+	// 刷新进行中时不再创建 goroutine
+	before := runtime.NumGoroutine()
+	for i := 0; i < 100; i++ {
+		gc.refresh("k")
+	}
+	if n := runtime.NumGoroutine() - before; n > 1 {
+		t.Fatalf("%d goroutines started for one refresh", n)
+	}
+	close(release)
+	waitValue(t, gc, "k", "k")
+	if n := atomic.LoadInt32(&loads); n != 1 {
+		t.Fatalf("loads = %d", n)
+	}
+}
+
+func TestGroup_StaleWhileRevalidate(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	getter := &versionedGetter{}
+	gc := NewGroup("swr", 2<<10, getter,
+		WithTTL(time.Minute), WithStaleWhileRevalidate(10*time.Second), withClock(clock.Now))
+
+	gc.Get("k")
+	clock.Advance(65 * time.Second)
+	if v, _ := gc.Get("k"); v.String() != "k-v1" {
+		t.Fatalf("got %s, want stale value", v)
+	}
+	waitValue(t, gc, "k", "k-v2")
+
+	// 超过 staleWhileRevalidate 后同步加载
+	clock.Advance(75 * time.Second)
+	if v, _ := gc.Get("k"); v.String() != "k-v3" {
+		t.Fatalf("got %s, want reloaded value", v)
+	}
+}
+
+func TestGroup_StaleIfError(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	getter := &versionedGetter{}
+	gc := NewGroup("sie", 2<<10, getter,
+		WithTTL(time.Minute), WithStaleIfError(time.Minute), withClock(clock.Now))
+
+	gc.Get("k")
+	atomic.StoreInt32(&getter.fail, 1)
+	clock.Advance(90 * time.Second)
+	if v, err := gc.Get("k"); err != nil || v.String() != "k-v1" {
+		t.Fatalf("got %s, %v, want stale value", v, err)
+	}
+
+	clock.Advance(time.Minute)
+	if _, err := gc.Get("k"); err == nil {
+		t.Fatal("stale value should not be served after window")
+	}
+}
+
+func TestGroup_Lifecycle(t *testing.T) {
+	r := NewRegistry()
+	var created []string
+	r.RegisterNewGroupHook(func(g *Group) {
+		created = append(created, g.Name())
+	})
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	})
+
+	g1, err := r.CreateGroup("lifecycle-1", 2<<10, getter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = r.CreateGroup("lifecycle-1", 2<<10, getter); err != ErrGroupExists {
+		t.Fatalf("err = %v, want ErrGroupExists", err)
+	}
+	r.NewGroup("lifecycle-2", 2<<10, getter)
+	if !reflect.DeepEqual(created, []string{"lifecycle-1", "lifecycle-2"}) {
+		t.Fatalf("hook got %v", created)
+	}
+
+	var names []string
+	for _, g := range r.ListGroups() {
+		if strings.HasPrefix(g.Name(), "lifecycle-") {
+			names = append(names, g.Name())
+		}
+	}
+	if !reflect.DeepEqual(names, []string{"lifecycle-1", "lifecycle-2"}) {
+		t.Fatalf("ListGroups got %v", names)
+	}
+
+	g1.Get("k1")
+	if !r.DeleteGroup("lifecycle-1") || r.DeleteGroup("lifecycle-1") || r.GetGroup("lifecycle-1") != nil {
+		t.Fatal("delete group failed")
+	}
+	if _, ok := g1.mainCache.get("k1"); ok {
+		t.Fatal("cache should be purged")
+	}
+
+	// 被覆盖的 Group 先写入 write-behind 队列中剩余的数据
+	store := newFakeStore()
+	old := r.NewGroup("lifecycle-2", 2<<10, getter, WithWriteBehind(store, 100, 0))
+	old.Set("k", []byte("v"))
+	r.NewGroup("lifecycle-2", 2<<10, getter)
+	if v, _ := store.Get("k"); string(v) != "v" {
+		t.Fatal("replaced group should flush pending writes")
+	}
+	if _, ok := old.mainCache.get("k"); ok {
+		t.Fatal("replaced group should be purged")
+	}
+}
+
+func TestGroup_SetCacheBytes(t *testing.T) {
+	gc := NewGroup("resize", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}))
+	for _, k := range []string{"k1", "k2", "k3"} {
+		gc.Get(k)
+	}
+	gc.SetCacheBytes(2 * (4 + entryOverhead))
+	if gc.CacheBytes() != 2*(4+entryOverhead) || gc.mainCache.lru.Len() != 2 {
+		t.Fatal("shrink failed")
+	}
+	if _, ok := gc.mainCache.get("k1"); ok {
+		t.Fatal("oldest key should be evicted")
+	}
+}
@@ -0,0 +1,432 @@
+package disk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"dcache/lru"
+)
+
+/*
+基于追加写日志的磁盘缓存：
+
+所有写入都追加到日志文件末尾，内存中的索引记录每个 key 在文件中的位置，
+索引按 LRU 策略淘汰，占用的大小按日志中的记录大小计算。
+被替换、删除或淘汰的记录成为日志中的无效数据，无效数据超过有效数据时在后台压缩日志，
+只保留有效的记录。压缩时只在开始和结束时短暂持有锁，复制期间的读写不受影响。
+
+日志中的一条记录，整数均为小端序：
+
+	crc32c uint32 | flags byte | uvarint len(key) | uvarint len(value) | key | value
+
+crc32c 覆盖 crc 之后的所有数据。删除时写入 flags 为 flagDeleted 的记录，重新打开时不再恢复该 key。
+打开时依次读取日志重建索引，遇到损坏或不完整的记录（例如写入时进程崩溃）时截断日志。
+*/
+
+const (
+	logName          = "dcache.log"
+	flagDeleted      = 1
+	headerSize       = 4 + 1 + 2*binary.MaxVarintLen64
+	defaultCompactAt = 4 << 20 // 无效数据至少达到该大小才压缩
+)
+
+var (
+	// ErrNotFound key 不在磁盘缓存中
+	ErrNotFound = errors.New("disk: not found")
+	// ErrCorrupt 记录的校验和不一致
+	ErrCorrupt = errors.New("disk: corrupt record")
+	// ErrClosed Store 已经关闭
+	ErrClosed = errors.New("disk: store closed")
+)
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// location 记录在日志中的位置
+type location struct {
+	offset int64
+	length int64
+}
+
+// Store 磁盘缓存，可以被多个 goroutine 同时使用
+type Store struct {
+	mu        sync.Mutex
+	dir       string
+	f         *os.File
+	size      int64 // 日志文件的大小
+	dead      int64 // 日志中无效数据的大小
+	compactAt int64 // 无效数据至少达到该大小才压缩
+	index     *lru.Cache[string, location]
+
+	compactMu  sync.Mutex     // 同一时间只有一个压缩
+	compacting bool           // 后台压缩正在进行，由 mu 保护
+	compactWG  sync.WaitGroup // 等待后台压缩结束
+
+	OnEvicted func(key string) // 记录因超过上限被淘汰时的回调，持有锁时调用
+}
+
+// Open 打开 dir 下的磁盘缓存，不存在时创建，maxBytes 为有效数据的上限，为0时不限制
+func Open(dir string, maxBytes int64) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, logName), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{dir: dir, f: f, compactAt: defaultCompactAt}
+	s.index = lru.NewCache(maxBytes, func(_ string, loc location) int64 {
+		return loc.length
+	}, func(key string, loc location, reason lru.EvictReason) {
+		s.dead += loc.length
+		if reason == lru.EvictCapacity && s.OnEvicted != nil {
+			s.OnEvicted(key)
+		}
+	})
+	if err := s.replay(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// replay 读取日志重建索引，日志末尾不完整的记录会被截断
+func (s *Store) replay() error {
+	r := bufio.NewReader(io.NewSectionReader(s.f, 0, 1<<62))
+	var offset int64
+	for {
+		key, _, deleted, n, err := readRecord(r)
+		if err != nil {
+			break
+		}
+		if deleted {
+			s.index.Delete(key)
+			s.dead += n
+		} else {
+			s.index.Add(key, location{offset: offset, length: n})
+		}
+		offset += n
+	}
+	if err := s.f.Truncate(offset); err != nil {
+		return err
+	}
+	s.size = offset
+	return nil
+}
+
+// Get 读取 key 对应的值，不存在时返回 ErrNotFound
+// 记录损坏时从索引中移除并返回 ErrCorrupt
+func (s *Store) Get(key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return nil, ErrClosed
+	}
+	loc, ok := s.index.Get(key)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	buf := make([]byte, loc.length)
+	if _, err := s.f.ReadAt(buf, loc.offset); err != nil {
+		return nil, err
+	}
+	k, value, _, err := decodeRecord(buf)
+	if err != nil || k != key {
+		s.index.Delete(key)
+		return nil, ErrCorrupt
+	}
+	return value, nil
+}
+
+// Put 写入一条记录，超过上限时淘汰最久未访问的记录
+func (s *Store) Put(key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return ErrClosed
+	}
+	loc, err := s.append(key, value, 0)
+	if err != nil {
+		return err
+	}
+	s.index.Add(key, loc)
+	s.maybeCompact()
+	return nil
+}
+
+// Delete 删除一条记录
+func (s *Store) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return ErrClosed
+	}
+	if !s.index.Contains(key) {
+		return nil
+	}
+	loc, err := s.append(key, nil, flagDeleted)
+	if err != nil {
+		return err
+	}
+	s.index.Delete(key)
+	s.dead += loc.length
+	s.maybeCompact()
+	return nil
+}
+
+// DeletePrefix 删除所有以 prefix 为前缀的记录，返回删除的条数
+func (s *Store) DeletePrefix(prefix string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return 0, ErrClosed
+	}
+	n := 0
+	for _, key := range s.index.Keys() {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		loc, err := s.append(key, nil, flagDeleted)
+		if err != nil {
+			return n, err
+		}
+		s.index.Delete(key)
+		s.dead += loc.length
+		n++
+	}
+	s.maybeCompact()
+	return n, nil
+}
+
+// append 在日志末尾追加一条记录
+func (s *Store) append(key string, value []byte, flags byte) (location, error) {
+	b := encodeRecord(key, value, flags)
+	if _, err := s.f.WriteAt(b, s.size); err != nil {
+		return location{}, err
+	}
+	loc := location{offset: s.size, length: int64(len(b))}
+	s.size += loc.length
+	return loc, nil
+}
+
+// maybeCompact 无效数据超过有效数据时在后台压缩日志，持有锁时调用
+// 压缩失败时保留原来的日志，之后写入时再次尝试
+func (s *Store) maybeCompact() {
+	if s.compacting || s.dead < s.compactAt || s.dead <= s.index.Bytes() {
+		return
+	}
+	s.compacting = true
+	s.compactWG.Add(1)
+	go func() {
+		defer s.compactWG.Done()
+		s.compactMu.Lock()
+		s.compact()
+		s.compactMu.Unlock()
+		s.mu.Lock()
+		s.compacting = false
+		s.mu.Unlock()
+	}()
+}
+
+// Compact 压缩日志，只保留有效的记录，返回时压缩已经完成
+func (s *Store) Compact() error {
+	s.compactMu.Lock()
+	defer s.compactMu.Unlock()
+	return s.compact()
+}
+
+// compact 按访问顺序从旧到新把有效的记录写入新的日志文件，再替换原来的日志，
+// 重新打开时可以还原访问顺序。
+// 复制记录时不持有锁，复制期间追加到原日志末尾的记录在替换前一起复制到新的日志中
+func (s *Store) compact() error {
+	type record struct {
+		key string
+		loc location
+	}
+	s.mu.Lock()
+	if s.f == nil {
+		s.mu.Unlock()
+		return ErrClosed
+	}
+	f, end := s.f, s.size
+	var records []record
+	s.index.RangeOldest(func(key string, loc location, _ time.Time) bool {
+		records = append(records, record{key: key, loc: loc})
+		return true
+	})
+	s.mu.Unlock()
+
+	path := filepath.Join(s.dir, logName)
+	tmpPath := path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	locs := make(map[string]location, len(records))
+	var offset int64
+	w := bufio.NewWriter(tmp)
+	for _, rec := range records {
+		buf := make([]byte, rec.loc.length)
+		if _, err = f.ReadAt(buf, rec.loc.offset); err != nil {
+			break
+		}
+		if _, err = w.Write(buf); err != nil {
+			break
+		}
+		locs[rec.key] = location{offset: offset, length: rec.loc.length}
+		offset += rec.loc.length
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err == nil && s.f != f {
+		err = ErrClosed
+	}
+	// 复制期间追加的记录，包括删除记录
+	if err == nil && s.size > end {
+		_, err = io.Copy(tmp, io.NewSectionReader(f, end, s.size-end))
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+
+	// 复制前的记录使用新的位置，复制期间追加的记录整体前移
+	delta := offset - end
+	var order []record
+	s.index.RangeOldest(func(key string, loc location, _ time.Time) bool {
+		if loc.offset >= end {
+			loc.offset += delta
+		} else {
+			loc = locs[key]
+		}
+		order = append(order, record{key: key, loc: loc})
+		return true
+	})
+	f.Close()
+	s.f = tmp
+	s.size += delta
+	// 按原来的顺序重新添加，访问顺序不变
+	for _, rec := range order {
+		s.index.Add(rec.key, rec.loc)
+	}
+	s.dead = s.size - s.index.Bytes()
+	return nil
+}
+
+// Len 有效记录的条数
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.index.Len()
+}
+
+// Bytes 有效数据的大小
+func (s *Store) Bytes() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.index.Bytes()
+}
+
+// Size 日志文件的大小，包括无效数据
+func (s *Store) Size() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
+
+// Close 等待后台压缩结束后关闭日志文件
+func (s *Store) Close() error {
+	s.mu.Lock()
+	f := s.f
+	s.f = nil
+	s.mu.Unlock()
+	if f == nil {
+		return nil
+	}
+	// 关闭后不会再开始新的压缩，进行中的压缩发现已关闭后放弃
+	s.compactWG.Wait()
+	return f.Close()
+}
+
+// encodeRecord 编码一条日志记录
+func encodeRecord(key string, value []byte, flags byte) []byte {
+	b := make([]byte, 4, headerSize+len(key)+len(value))
+	b = append(b, flags)
+	var tmp [binary.MaxVarintLen64]byte
+	b = append(b, tmp[:binary.PutUvarint(tmp[:], uint64(len(key)))]...)
+	b = append(b, tmp[:binary.PutUvarint(tmp[:], uint64(len(value)))]...)
+	b = append(b, key...)
+	b = append(b, value...)
+	binary.LittleEndian.PutUint32(b, crc32.Checksum(b[4:], crc32c))
+	return b
+}
+
+// decodeRecord 解析一条完整的日志记录并校验
+func decodeRecord(b []byte) (key string, value []byte, deleted bool, err error) {
+	if len(b) < 5 || crc32.Checksum(b[4:], crc32c) != binary.LittleEndian.Uint32(b) {
+		return "", nil, false, ErrCorrupt
+	}
+	flags := b[4]
+	b = b[5:]
+	klen, n := binary.Uvarint(b)
+	if n <= 0 {
+		return "", nil, false, ErrCorrupt
+	}
+	b = b[n:]
+	vlen, n := binary.Uvarint(b)
+	if n <= 0 || klen > uint64(len(b)-n) || klen+vlen != uint64(len(b)-n) {
+		return "", nil, false, ErrCorrupt
+	}
+	b = b[n:]
+	return string(b[:klen]), b[klen:], flags&flagDeleted != 0, nil
+}
+
+// readRecord 从日志中读取一条记录，返回记录的大小
+func readRecord(r *bufio.Reader) (key string, value []byte, deleted bool, n int64, err error) {
+	var hdr [5]byte
+	if _, err = io.ReadFull(r, hdr[:]); err != nil {
+		return
+	}
+	klen, err := binary.ReadUvarint(r)
+	if err != nil {
+		return
+	}
+	vlen, err := binary.ReadUvarint(r)
+	if err != nil {
+		return
+	}
+	if klen+vlen < klen {
+		err = ErrCorrupt
+		return
+	}
+	var buf bytes.Buffer
+	buf.Write(hdr[:])
+	var tmp [binary.MaxVarintLen64]byte
+	buf.Write(tmp[:binary.PutUvarint(tmp[:], klen)])
+	buf.Write(tmp[:binary.PutUvarint(tmp[:], vlen)])
+	// 按实际读到的数据分配内存，长度损坏时不会一次分配过多
+	if _, err = io.CopyN(&buf, r, int64(klen+vlen)); err != nil {
+		return
+	}
+	key, value, deleted, err = decodeRecord(buf.Bytes())
+	return key, value, deleted, int64(buf.Len()), err
+}
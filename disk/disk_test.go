@@ -0,0 +1,172 @@
+package disk
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestStore_PutGet(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Put("k1", []byte("v1"))
+	s.Put("k2", []byte("v2"))
+	s.Put("k1", []byte("v1-new"))
+	s.Delete("k2")
+
+	if v, err := s.Get("k1"); err != nil || string(v) != "v1-new" {
+		t.Fatalf("get k1 = %q, %v", v, err)
+	}
+	if _, err := s.Get("k2"); err != ErrNotFound {
+		t.Fatalf("deleted key err = %v", err)
+	}
+	s.Close()
+	if _, err := s.Get("k1"); err != ErrClosed {
+		t.Fatalf("err = %v, want ErrClosed", err)
+	}
+
+	// 重新打开时从日志恢复索引
+	s, err = Open(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if v, err := s.Get("k1"); err != nil || string(v) != "v1-new" {
+		t.Fatalf("reopen get k1 = %q, %v", v, err)
+	}
+	if _, err := s.Get("k2"); err != ErrNotFound || s.Len() != 1 {
+		t.Fatal("deleted key should not be restored")
+	}
+	s.Put("k3", []byte("v3"))
+	s.Put("x1", []byte("x1"))
+	if n, err := s.DeletePrefix("k"); err != nil || n != 2 || s.Len() != 1 {
+		t.Fatalf("delete prefix n = %d, err = %v", n, err)
+	}
+}
+
+func TestStore_MaxBytes(t *testing.T) {
+	record := int64(len(encodeRecord("k1", []byte("v1"), 0)))
+	s, err := Open(t.TempDir(), 2*record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.Put("k1", []byte("v1"))
+	s.Put("k2", []byte("v2"))
+	s.Get("k1")
+	s.Put("k3", []byte("v3"))
+	if _, err := s.Get("k2"); err != ErrNotFound {
+		t.Fatal("least recently used key should be evicted")
+	}
+	if s.Bytes() != 2*record || s.Size() != 3*record {
+		t.Fatalf("bytes = %d, size = %d", s.Bytes(), s.Size())
+	}
+}
+
+func TestStore_Compact(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.compactAt = 1
+	for i := 0; i < 10; i++ {
+		s.Put("k1", []byte("v1"))
+	}
+	s.Put("k2", []byte("v2"))
+	// 无效数据超过有效数据时在后台自动压缩
+	s.compactWG.Wait()
+	record := int64(len(encodeRecord("k1", []byte("v1"), 0)))
+	if s.Size() > 3*record {
+		t.Fatalf("log not compacted, size = %d", s.Size())
+	}
+	s.Get("k1")
+	if err := s.Compact(); err != nil || s.Size() != 2*record {
+		t.Fatalf("compact size = %d, err = %v", s.Size(), err)
+	}
+	s.Close()
+
+	// 压缩后保留访问顺序
+	s, err = Open(dir, record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, err := s.Get("k1"); err != nil || s.Len() != 1 {
+		t.Fatalf("most recently used key should be kept, err = %v", err)
+	}
+}
+
+func TestStore_Corrupt(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Put("k1", []byte("v1"))
+	s.Put("k2", []byte("v2"))
+	size := s.Size()
+	s.Close()
+
+	// 模拟写入时崩溃，日志末尾只有半条记录
+	path := filepath.Join(dir, logName)
+	b, _ := os.ReadFile(path)
+	os.WriteFile(path, b[:size-3], 0o644)
+	s, err = Open(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get("k2"); err != ErrNotFound || s.Len() != 1 || s.Size() != size/2 {
+		t.Fatalf("truncated record should be dropped, len = %d, size = %d", s.Len(), s.Size())
+	}
+
+	// 读取时发现损坏的记录
+	s.f.WriteAt([]byte{'x'}, size/2-1)
+	if _, err := s.Get("k1"); err != ErrCorrupt || s.Len() != 0 {
+		t.Fatalf("err = %v, want ErrCorrupt", err)
+	}
+	s.Close()
+}
+
+func TestStore_CompactConcurrentWrites(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.compactAt = 1
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			s.Compact()
+		}
+	}()
+	// 压缩期间的写入和删除不会丢失
+	for i := 0; i < 200; i++ {
+		s.Put("k"+strconv.Itoa(i%10), []byte(strconv.Itoa(i)))
+		s.Delete("x")
+		s.Put("x", []byte("x"))
+	}
+	s.Delete("k0")
+	<-done
+	s.Close()
+
+	s, err = Open(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	for i := 1; i < 10; i++ {
+		if v, err := s.Get("k" + strconv.Itoa(i)); err != nil || string(v) != strconv.Itoa(190+i) {
+			t.Fatalf("k%d = %q, %v", i, v, err)
+		}
+	}
+	if _, err := s.Get("k0"); err != ErrNotFound || s.Len() != 10 {
+		t.Fatalf("k0 err = %v, len = %d", err, s.Len())
+	}
+}
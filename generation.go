@@ -0,0 +1,57 @@
+package dcache
+
+import (
+	"dcache/cachepb"
+	"strconv"
+	"sync/atomic"
+)
+
+/*
+Group 的代（generation）：
+
+每条记录保存加载时 Group 的代，代增加后之前的记录全部失效，不需要逐条删除。
+旧代的记录在被访问时删除，从未被访问的由淘汰策略回收。
+代不为0时，选择节点使用 key 加上代，代增加后 key 会分布到新的节点上；
+节点间的请求携带请求方的代，接收方的代较小时更新为该值。
+*/
+
+// Generation 返回 Group 当前的代，初始为0
+func (g *Group) Generation() uint64 {
+	return atomic.LoadUint64(&g.gen)
+}
+
+// BumpGeneration 本机 Group 的代加一，本机之前的缓存全部失效，返回新的代
+func (g *Group) BumpGeneration() uint64 {
+	gen := atomic.AddUint64(&g.gen, 1)
+	g.leases.voidAll()
+	return gen
+}
+
+// BumpGenerationEverywhere 本机 Group 的代加一，并广播给所有远程节点，
+// 所有节点之前的缓存全部失效，返回新的代
+func (g *Group) BumpGenerationEverywhere() (uint64, error) {
+	gen := g.BumpGeneration()
+	return gen, g.broadcast(&cachepb.InvalidateRequest{Group: g.name, Generation: gen})
+}
+
+// observeGeneration 收到更大的代时更新本机的代
+func (g *Group) observeGeneration(gen uint64) {
+	for {
+		cur := atomic.LoadUint64(&g.gen)
+		if gen <= cur {
+			return
+		}
+		if atomic.CompareAndSwapUint64(&g.gen, cur, gen) {
+			g.leases.voidAll()
+			return
+		}
+	}
+}
+
+// genKey 选择节点以及合并请求时使用的key
+func genKey(key string, gen uint64) string {
+	if gen == 0 {
+		return key
+	}
+	return key + "\x00" + strconv.FormatUint(gen, 10)
+}
@@ -0,0 +1,118 @@
+package dcache
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestGroup_BumpGeneration(t *testing.T) {
+	loads := 0
+	gc := NewRegistry().NewGroup("generation", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		loads++
+		if key == "missing" {
+			return nil, ErrNotFound
+		}
+		return []byte(key), nil
+	}), WithNegativeCache(time.Minute, 2<<10))
+	gc.Get("k1")
+	gc.Get("missing")
+	if loads != 2 {
+		t.Fatalf("loads = %d", loads)
+	}
+
+	if gen := gc.BumpGeneration(); gen != 1 || gc.Generation() != 1 {
+		t.Fatalf("generation = %d", gen)
+	}
+	// 旧代的记录不再命中，访问时删除
+	if v, err := gc.Get("k1"); err != nil || v.String() != "k1" || loads != 3 {
+		t.Fatalf("get k1 = %v, %v, loads = %d", v, err, loads)
+	}
+	if gc.isNegative("missing") {
+		t.Fatal("negative entry of old generation should be ignored")
+	}
+	if gc.mainCache.lru.Len() != 1 {
+		t.Fatalf("len = %d", gc.mainCache.lru.Len())
+	}
+	gc.Get("k1")
+	if loads != 3 {
+		t.Fatal("entry of new generation should be cached")
+	}
+
+	// 较小的代不会使本机的代回退
+	gc.observeGeneration(0)
+	if gc.Generation() != 1 {
+		t.Fatal("generation should not go backwards")
+	}
+}
+
+func TestGroup_BumpGenerationEverywhere(t *testing.T) {
+	peer := &flakyInvalidator{}
+	gc := NewRegistry().NewGroup("generation-broadcast", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}))
+	gc.RegisterPeers(&listPicker{peers: []PeerGetter{peer}})
+	gen, err := gc.BumpGenerationEverywhere()
+	if err != nil || gen != 1 {
+		t.Fatalf("gen = %d, err = %v", gen, err)
+	}
+	if len(peer.reqs) != 1 || peer.reqs[0].Generation != 1 {
+		t.Fatalf("unexpected requests: %v", peer.reqs)
+	}
+
+	// 通过 HTTP 广播，远程节点的代随之增加
+	nodes := []*invalidateNode{newInvalidateNode(), newInvalidateNode()}
+	var addrs []string
+	for _, n := range nodes {
+		defer n.srv.Close()
+		addrs = append(addrs, n.srv.URL)
+	}
+	for _, n := range nodes {
+		n.pool.Set(HttpGetter, addrs...)
+	}
+	nodes[0].group.BumpGeneration()
+	if gen, err := nodes[0].group.BumpGenerationEverywhere(); err != nil || gen != 2 {
+		t.Fatalf("gen = %d, err = %v", gen, err)
+	}
+	if gen := nodes[1].group.Generation(); gen != 2 {
+		t.Fatalf("remote generation = %d", gen)
+	}
+
+	// 请求携带的代较大时接收方更新本机的代
+	resp, err := http.Get(nodes[1].srv.URL + defaultBasePath + "invalidate-cluster/k?" +
+		url.Values{"generation": {"5"}}.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if gen := nodes[1].group.Generation(); gen != 5 {
+		t.Fatalf("remote generation = %d", gen)
+	}
+}
+
+func TestGroup_SnapshotGeneration(t *testing.T) {
+	newGroup := func(name string) *Group {
+		return NewRegistry().NewGroup(name, 2<<10, GetterFunc(func(key string) ([]byte, error) {
+			return []byte(key), nil
+		}))
+	}
+	src := newGroup("generation-snapshot")
+	src.Get("old")
+	src.BumpGeneration()
+	src.Get("new")
+	var buf bytes.Buffer
+	if err := src.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := newGroup("generation-snapshot")
+	n, err := dst.Restore(&buf)
+	if err != nil || n != 1 || dst.Generation() != 1 {
+		t.Fatalf("restored %d, generation = %d, err = %v", n, dst.Generation(), err)
+	}
+	if _, ok := dst.mainCache.getGen("new", 1); !ok {
+		t.Fatal("entry of current generation should be restored")
+	}
+}
@@ -1,8 +1,15 @@
 module dcache
 
-go 1.14
+go 1.18
 
 require (
 	github.com/golang/protobuf v1.3.5
 	google.golang.org/grpc v1.28.0
 )
+
+require (
+	golang.org/x/net v0.0.0-20190311183353-d8887717615a // indirect
+	golang.org/x/sys v0.0.0-20190215142949-d0b11bdaac8a // indirect
+	golang.org/x/text v0.3.0 // indirect
+	google.golang.org/genproto v0.0.0-20190819201941-24fa4b261c55 // indirect
+)
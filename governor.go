@@ -0,0 +1,186 @@
+package dcache
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+/*
+根据 Go 运行时的内存使用情况自适应地调整缓存大小：
+
+Governor 定期读取堆内存（runtime.MemStats.HeapAlloc），与软上限比较：
+  - 超过软上限时，按超出的部分等比例缩小 Registry 中所有 Group 的实际内存上限
+  - 低于软上限的 growThreshold 时，逐步放大，最多恢复到各个 Group 设置的 cacheBytes
+
+被淘汰的记录在下一次 GC 之前仍然计入堆内存，因此缩小之后要等到发生过 GC 才会再次缩小，
+避免把缓存缩得过小。cacheBytes 为 0（不限制）的 Group 不受影响。
+*/
+
+const (
+	defaultGovernorInterval = time.Second
+	defaultMinRatio         = 0.05 // 最多缩小到设置的 5%
+	growThreshold           = 0.9  // 堆内存低于软上限的 90% 时放大
+	growStep                = 1.1  // 每次放大 10%
+)
+
+// Governor 根据进程的内存压力调整 Registry 中各个 Group 的内存上限
+type Governor struct {
+	registry  *Registry
+	softLimit uint64        // 堆内存的软上限
+	interval  time.Duration // 采样间隔
+	minRatio  float64       // 最小的缩放比例
+
+	readMemStats func(*runtime.MemStats) // 读取内存统计，测试时可替换
+
+	mu       sync.Mutex
+	ratio    float64 // 当前的缩放比例
+	waitGC   bool    // 缩小之后还没有发生过 GC
+	shrinkGC uint32  // 缩小时的 GC 次数
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// GovernorOption Governor 的可选配置
+type GovernorOption func(*Governor)
+
+// WithGovernorInterval 设置采样间隔，默认为 1 秒
+func WithGovernorInterval(interval time.Duration) GovernorOption {
+	return func(gv *Governor) {
+		gv.interval = interval
+	}
+}
+
+// WithMinRatio 设置最小的缩放比例，默认为 0.05
+func WithMinRatio(ratio float64) GovernorOption {
+	return func(gv *Governor) {
+		gv.minRatio = ratio
+	}
+}
+
+// NewGovernor 创建一个 Governor，softLimit 为堆内存的软上限，调用 Start 之后开始工作
+func (r *Registry) NewGovernor(softLimit uint64, opts ...GovernorOption) *Governor {
+	gv := &Governor{
+		registry:     r,
+		softLimit:    softLimit,
+		interval:     defaultGovernorInterval,
+		minRatio:     defaultMinRatio,
+		readMemStats: runtime.ReadMemStats,
+		ratio:        1,
+	}
+	for _, opt := range opts {
+		opt(gv)
+	}
+	return gv
+}
+
+// NewGovernor 为默认的 Registry 创建一个 Governor
+func NewGovernor(softLimit uint64, opts ...GovernorOption) *Governor {
+	return DefaultRegistry.NewGovernor(softLimit, opts...)
+}
+
+// Start 在后台定期调整缓存大小，重复调用无效
+func (gv *Governor) Start() {
+	gv.mu.Lock()
+	defer gv.mu.Unlock()
+	if gv.stop != nil {
+		return
+	}
+	gv.stop = make(chan struct{})
+	gv.done = make(chan struct{})
+	go gv.run(gv.stop, gv.done)
+}
+
+func (gv *Governor) run(stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(gv.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			gv.Step()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Stop 停止调整，并将所有 Group 恢复为设置的 cacheBytes
+func (gv *Governor) Stop() {
+	gv.mu.Lock()
+	stop, done := gv.stop, gv.done
+	gv.stop, gv.done = nil, nil
+	gv.mu.Unlock()
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+
+	gv.mu.Lock()
+	defer gv.mu.Unlock()
+	gv.ratio = 1
+	gv.waitGC = false
+	gv.apply()
+}
+
+// Ratio 当前的缩放比例，1 表示不缩放
+func (gv *Governor) Ratio() float64 {
+	gv.mu.Lock()
+	defer gv.mu.Unlock()
+	return gv.ratio
+}
+
+// Step 采样一次内存使用并调整缓存大小，返回调整后的缩放比例
+func (gv *Governor) Step() float64 {
+	var m runtime.MemStats
+	gv.readMemStats(&m)
+
+	gv.mu.Lock()
+	defer gv.mu.Unlock()
+	if gv.waitGC && m.NumGC != gv.shrinkGC {
+		gv.waitGC = false
+	}
+
+	used := m.HeapAlloc
+	switch {
+	case used > gv.softLimit && !gv.waitGC:
+		// 假设缓存已经用满，缩小的比例使缓存释放的内存等于超出的部分
+		over := int64(used - gv.softLimit)
+		cached := gv.cachedBytes()
+		ratio := gv.minRatio
+		if over < cached {
+			ratio = gv.ratio * float64(cached-over) / float64(cached)
+		}
+		if ratio < gv.minRatio {
+			ratio = gv.minRatio
+		}
+		gv.ratio = ratio
+		gv.waitGC = true
+		gv.shrinkGC = m.NumGC
+	case float64(used) < float64(gv.softLimit)*growThreshold && gv.ratio < 1:
+		gv.ratio *= growStep
+		if gv.ratio > 1 {
+			gv.ratio = 1
+		}
+	}
+	// 每次都重新设置，之后新建的 Group 也会被调整
+	gv.apply()
+	return gv.ratio
+}
+
+// cachedBytes 所有 Group 当前使用的内存
+func (gv *Governor) cachedBytes() int64 {
+	var total int64
+	for _, g := range gv.registry.ListGroups() {
+		total += g.mainCache.bytes()
+	}
+	return total
+}
+
+// apply 按当前的缩放比例设置所有 Group 的内存上限，调用时需要持有 mu
+func (gv *Governor) apply() {
+	for _, g := range gv.registry.ListGroups() {
+		g.mainCache.scale(gv.ratio)
+	}
+}
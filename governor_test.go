@@ -0,0 +1,68 @@
+package dcache
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+)
+
+func TestGovernor_Step(t *testing.T) {
+	r := NewRegistry()
+	size := 2 + 5 + entryOverhead
+	gc := r.NewGroup("governed", 10*size, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("value"), nil
+	}))
+	for i := 0; i < 10; i++ {
+		gc.Get(fmt.Sprintf("k%d", i))
+	}
+
+	var stats runtime.MemStats
+	gv := r.NewGovernor(1000, WithMinRatio(0.1))
+	gv.readMemStats = func(m *runtime.MemStats) { *m = stats }
+
+	// 超出软上限一半的缓存，缩小一半
+	stats.HeapAlloc = 1000 + uint64(5*size)
+	if ratio := gv.Step(); ratio != 0.5 {
+		t.Fatalf("ratio = %v, want 0.5", ratio)
+	}
+	if gc.mainCache.lru.Len() != 5 || gc.CacheBytes() != 10*size {
+		t.Fatalf("len = %d, cacheBytes = %d", gc.mainCache.lru.Len(), gc.CacheBytes())
+	}
+
+	// 发生 GC 之前不再缩小
+	if ratio := gv.Step(); ratio != 0.5 {
+		t.Fatalf("ratio = %v before gc", ratio)
+	}
+	// GC 之后仍然超出很多，缩小到最小比例
+	stats.NumGC++
+	stats.HeapAlloc = 1 << 20
+	if ratio := gv.Step(); ratio != 0.1 {
+		t.Fatalf("ratio = %v, want 0.1", ratio)
+	}
+	if gc.mainCache.lru.Len() != 1 {
+		t.Fatalf("len = %d", gc.mainCache.lru.Len())
+	}
+
+	// 内存压力消失后逐步放大
+	stats.HeapAlloc = 100
+	prev := gv.Ratio()
+	for i := 0; i < 100 && gv.Ratio() < 1; i++ {
+		if ratio := gv.Step(); ratio <= prev {
+			t.Fatalf("ratio should grow, got %v", ratio)
+		}
+		prev = gv.Ratio()
+	}
+	if gv.Ratio() != 1 || gc.mainCache.lru.MaxBytes() != 10*size {
+		t.Fatalf("ratio = %v, max = %d", gv.Ratio(), gc.mainCache.lru.MaxBytes())
+	}
+
+	// Stop 恢复设置的大小
+	gv.Start()
+	stats.NumGC++
+	stats.HeapAlloc = 1 << 20
+	gv.Step()
+	gv.Stop()
+	if gv.Ratio() != 1 || gc.mainCache.lru.MaxBytes() != 10*size {
+		t.Fatal("stop should restore cache bytes")
+	}
+}
@@ -2,17 +2,22 @@
 package dcache
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"dcache/cachepb"
 	"dcache/consistenthash"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"github.com/golang/protobuf/proto"
 	"google.golang.org/grpc"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 )
@@ -20,11 +25,20 @@ import (
 const defaultBasePath = "/_cache/"
 const defaultReplicas = 3
 
+// 分块传输
+const (
+	streamHeader      = "X-Dcache-Stream" // 请求头中带有该字段时，以分块的方式返回
+	streamContentType = "application/x-dcache-stream"
+	streamChunkSize   = 64 << 10 // 每次发送的最大字节数
+)
+
 type HTTPPool struct {
-	self     string //记录自身地址
-	basePath string // 通信地址前缀
+	self     string    //记录自身地址
+	basePath string    // 通信地址前缀
+	registry *Registry // 处理请求时从中查找 Group
 
 	peers   *consistenthash.Map
+	addrs   []string // 所有节点的地址
 	mu      sync.Mutex
 	getters map[string]PeerGetter
 }
@@ -33,6 +47,7 @@ func NewHTTPPool(self string) *HTTPPool {
 	return &HTTPPool{
 		self:     self,
 		basePath: defaultBasePath,
+		registry: DefaultRegistry,
 	}
 }
 
@@ -45,6 +60,22 @@ func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		panic("HTTPPool serving unexpect path..." + r.URL.Path)
 	}
 	p.Log("%s %s", r.Method, r.URL.Path)
+	if r.Method == http.MethodPost {
+		// POST /<basepath>/<groupname> 批量获取
+		p.serveBatch(w, r, r.URL.Path[len(p.basePath):])
+		return
+	}
+	if r.Method == http.MethodPut {
+		// PUT /<basepath>/<groupname>/<key> 写入
+		// PUT /<basepath>/<groupname> CompareAndSet
+		name := r.URL.Path[len(p.basePath):]
+		if i := strings.IndexByte(name, '/'); i >= 0 {
+			p.servePut(w, r, name[:i])
+		} else {
+			p.serveCompareAndSet(w, r, name)
+		}
+		return
+	}
 	// /<basepath>/<groupname>/<key> required
 	parts := strings.SplitN(r.URL.Path[len(p.basePath):], "/", 2)
 	if len(parts) != 2 {
@@ -53,20 +84,42 @@ func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	groupName, key := parts[0], parts[1]
-	group := GetGroup(groupName)
+	group := p.registry.GetGroup(groupName)
 	if group == nil {
 		// 本机没有这个缓存group
 		http.Error(w, "no such group", http.StatusNotFound)
 		return
 	}
 
-	view, err := group.Get(key)
+	// 请求方的代较大时更新本机的代
+	if gen, err := strconv.ParseUint(r.URL.Query().Get("generation"), 10, 64); err == nil {
+		group.observeGeneration(gen)
+	}
+
+	if r.Method == http.MethodDelete {
+		// DELETE /<basepath>/<groupname>/<key>[?prefix=true|?tag=<tag>] 删除本机的缓存
+		p.serveInvalidate(w, group, &cachepb.InvalidateRequest{
+			Group:  groupName,
+			Key:    key,
+			Prefix: r.URL.Query().Get("prefix") != "",
+			Tag:    r.URL.Query().Get("tag"),
+		})
+		return
+	}
+
+	if r.Header.Get(streamHeader) != "" {
+		p.serveStream(w, group, key)
+		return
+	}
+
+	maxSize, _ := strconv.ParseUint(r.URL.Query().Get("max_size"), 10, 64)
+	resp, err := group.response(key, maxSize)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	body, err := proto.Marshal(&cachepb.Response{Value: view.ByteSlice()})
+	body, err := proto.Marshal(resp)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -76,20 +129,223 @@ func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Write(body)
 }
 
+// serveStream 以分块的方式返回
+// 响应体为 uvarint 编码的 header 长度 + protobuf 编码的 header + value 的原始数据
+func (p *HTTPPool) serveStream(w http.ResponseWriter, group *Group, key string) {
+	header, value, err := group.streamResponse(key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	hb, err := proto.Marshal(header)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", streamContentType)
+	prefix := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(prefix, uint64(len(hb)))
+	if _, err = w.Write(append(prefix[:n], hb...)); err != nil {
+		return
+	}
+	flusher, _ := w.(http.Flusher)
+	eachStreamPiece(value, func(piece []byte) error {
+		if _, err := w.Write(piece); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+}
+
+// eachStreamPiece 将 value 切分为不超过 streamChunkSize 的块依次处理，不会拷贝数据
+func eachStreamPiece(value ByteView, fn func(piece []byte) error) error {
+	var err error
+	value.eachChunk(func(c []byte) bool {
+		for len(c) > 0 && err == nil {
+			n := streamChunkSize
+			if n > len(c) {
+				n = len(c)
+			}
+			err = fn(c[:n])
+			c = c[n:]
+		}
+		return err == nil
+	})
+	return err
+}
+
+// serveBatch 处理批量请求，请求体和响应体都是 protobuf 编码
+func (p *HTTPPool) serveBatch(w http.ResponseWriter, r *http.Request, groupName string) {
+	group := p.registry.GetGroup(groupName)
+	if group == nil {
+		http.Error(w, "no such group", http.StatusNotFound)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req := &cachepb.BatchRequest{}
+	if err = proto.Unmarshal(body, req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	group.observeGeneration(req.GetGeneration())
+	body, err = proto.Marshal(group.batchResponse(req.GetKeys()))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(body)
+}
+
+// servePut 处理写入请求，请求体和响应体都是 protobuf 编码
+func (p *HTTPPool) servePut(w http.ResponseWriter, r *http.Request, groupName string) {
+	group := p.registry.GetGroup(groupName)
+	if group == nil {
+		http.Error(w, "no such group", http.StatusNotFound)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req := &cachepb.PutRequest{}
+	if err = proto.Unmarshal(body, req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := group.putResponse(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	body, err = proto.Marshal(resp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(body)
+}
+
+// serveCompareAndSet 处理 CompareAndSet 请求，请求体和响应体都是 protobuf 编码
+func (p *HTTPPool) serveCompareAndSet(w http.ResponseWriter, r *http.Request, groupName string) {
+	group := p.registry.GetGroup(groupName)
+	if group == nil {
+		http.Error(w, "no such group", http.StatusNotFound)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req := &cachepb.CompareAndSetRequest{}
+	if err = proto.Unmarshal(body, req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := group.compareAndSetResponse(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	body, err = proto.Marshal(resp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(body)
+}
+
+// serveInvalidate 处理删除请求，响应体为 protobuf 编码的 InvalidateResponse
+func (p *HTTPPool) serveInvalidate(w http.ResponseWriter, group *Group, req *cachepb.InvalidateRequest) {
+	body, err := proto.Marshal(&cachepb.InvalidateResponse{Removed: group.invalidate(req)})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(body)
+}
+
 var _ cachepb.GroupCacheServer = (*HTTPPool)(nil)
 
 func (p *HTTPPool) Get(ctx context.Context, req *cachepb.Request) (*cachepb.Response, error) {
 	groupName := req.GetGroup()
-	group := GetGroup(groupName)
+	group := p.registry.GetGroup(groupName)
 	if group == nil {
 		// 本机没有这个缓存group
 		return nil, errors.New("no such group")
 	}
-	view, err := group.Get(req.GetKey())
+	group.observeGeneration(req.GetGeneration())
+	return group.response(req.GetKey(), req.GetMaxSize())
+}
+
+func (p *HTTPPool) BatchGet(ctx context.Context, req *cachepb.BatchRequest) (*cachepb.BatchResponse, error) {
+	group := p.registry.GetGroup(req.GetGroup())
+	if group == nil {
+		return nil, errors.New("no such group")
+	}
+	group.observeGeneration(req.GetGeneration())
+	return group.batchResponse(req.GetKeys()), nil
+}
+
+func (p *HTTPPool) GetStream(req *cachepb.Request, stream cachepb.GroupCache_GetStreamServer) error {
+	group := p.registry.GetGroup(req.GetGroup())
+	if group == nil {
+		return errors.New("no such group")
+	}
+	group.observeGeneration(req.GetGeneration())
+	header, value, err := group.streamResponse(req.GetKey())
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if value.Len() == 0 {
+		return stream.Send(&cachepb.Chunk{Header: header})
+	}
+	// 第一块携带 header
+	return eachStreamPiece(value, func(piece []byte) error {
+		chunk := &cachepb.Chunk{Header: header, Data: piece}
+		header = nil
+		return stream.Send(chunk)
+	})
+}
+
+func (p *HTTPPool) Invalidate(ctx context.Context, req *cachepb.InvalidateRequest) (*cachepb.InvalidateResponse, error) {
+	group := p.registry.GetGroup(req.GetGroup())
+	if group == nil {
+		return nil, errors.New("no such group")
+	}
+	return &cachepb.InvalidateResponse{Removed: group.invalidate(req)}, nil
+}
+
+func (p *HTTPPool) Put(ctx context.Context, req *cachepb.PutRequest) (*cachepb.PutResponse, error) {
+	group := p.registry.GetGroup(req.GetGroup())
+	if group == nil {
+		return nil, errors.New("no such group")
+	}
+	return group.putResponse(req)
+}
+
+func (p *HTTPPool) CompareAndSet(ctx context.Context, req *cachepb.CompareAndSetRequest) (*cachepb.CompareAndSetResponse, error) {
+	group := p.registry.GetGroup(req.GetGroup())
+	if group == nil {
+		return nil, errors.New("no such group")
 	}
-	return &cachepb.Response{Value: view.ByteSlice()}, err
+	return group.compareAndSetResponse(req)
 }
 
 type httpGetter struct {
@@ -98,8 +354,30 @@ type httpGetter struct {
 
 var _ PeerGetter = (*httpGetter)(nil)
 
+// keyURL 返回 key 对应的地址，query 不为空时附加在地址之后
+func (h *httpGetter) keyURL(group, key string, query url.Values) string {
+	u := fmt.Sprintf("%v%v/%v", h.baseURL, url.QueryEscape(group), url.QueryEscape(key))
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	return u
+}
+
+// generationQuery 请求中携带的代
+func generationQuery(gen uint64) url.Values {
+	query := url.Values{}
+	if gen > 0 {
+		query.Set("generation", strconv.FormatUint(gen, 10))
+	}
+	return query
+}
+
 func (h *httpGetter) Get(in *cachepb.Request, out *cachepb.Response) error {
-	u := fmt.Sprintf("%v%v/%v", h.baseURL, url.QueryEscape(in.GetGroup()), url.QueryEscape(in.GetKey()))
+	query := generationQuery(in.GetGeneration())
+	if in.GetMaxSize() > 0 {
+		query.Set("max_size", strconv.FormatUint(in.GetMaxSize(), 10))
+	}
+	u := h.keyURL(in.GetGroup(), in.GetKey(), query)
 	log.Println("get remote dcache url", u)
 	res, err := http.Get(u)
 	if err != nil {
@@ -124,6 +402,177 @@ func (h *httpGetter) Get(in *cachepb.Request, out *cachepb.Response) error {
 
 }
 
+var _ BatchPeerGetter = (*httpGetter)(nil)
+
+func (h *httpGetter) BatchGet(in *cachepb.BatchRequest, out *cachepb.BatchResponse) error {
+	u := fmt.Sprintf("%v%v", h.baseURL, url.QueryEscape(in.GetGroup()))
+	body, err := proto.Marshal(in)
+	if err != nil {
+		return err
+	}
+	res, err := http.Post(u, "application/octet-stream", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("server returned: %v", res.StatusCode)
+	}
+
+	body, err = ioutil.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("reading response body: %v", err)
+	}
+	if err = proto.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("decoding response body: %v", err)
+	}
+	return nil
+}
+
+var _ StreamPeerGetter = (*httpGetter)(nil)
+
+func (h *httpGetter) GetStream(in *cachepb.Request) (*cachepb.Response, [][]byte, error) {
+	u := h.keyURL(in.GetGroup(), in.GetKey(), generationQuery(in.GetGeneration()))
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set(streamHeader, "1")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("server returned: %v", res.StatusCode)
+	}
+
+	body := bufio.NewReader(res.Body)
+	n, err := binary.ReadUvarint(body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading response header: %v", err)
+	}
+	hb := make([]byte, n)
+	if _, err = io.ReadFull(body, hb); err != nil {
+		return nil, nil, fmt.Errorf("reading response header: %v", err)
+	}
+	header := &cachepb.Response{}
+	if err = proto.Unmarshal(hb, header); err != nil {
+		return nil, nil, fmt.Errorf("decoding response header: %v", err)
+	}
+
+	// 按 header 中的大小逐块读取 value，响应体不完整时返回错误
+	var chunks [][]byte
+	for remain := header.GetSize(); remain > 0; {
+		n := uint64(streamChunkSize)
+		if remain < n {
+			n = remain
+		}
+		chunk := make([]byte, n)
+		if _, err = io.ReadFull(body, chunk); err != nil {
+			return nil, nil, fmt.Errorf("reading response body: %v", err)
+		}
+		chunks = append(chunks, chunk)
+		remain -= n
+	}
+	return header, chunks, nil
+}
+
+var _ PeerInvalidator = (*httpGetter)(nil)
+
+func (h *httpGetter) Invalidate(in *cachepb.InvalidateRequest, out *cachepb.InvalidateResponse) error {
+	query := generationQuery(in.GetGeneration())
+	if in.GetPrefix() {
+		query.Set("prefix", "true")
+	}
+	if in.GetTag() != "" {
+		query.Set("tag", in.GetTag())
+	}
+	req, err := http.NewRequest(http.MethodDelete, h.keyURL(in.GetGroup(), in.GetKey(), query), nil)
+	if err != nil {
+		return err
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("server returned: %v", res.StatusCode)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("reading response body: %v", err)
+	}
+	if err = proto.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("decoding response body: %v", err)
+	}
+	return nil
+}
+
+var _ PeerPutter = (*httpGetter)(nil)
+
+func (h *httpGetter) Put(in *cachepb.PutRequest, out *cachepb.PutResponse) error {
+	body, err := proto.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPut, h.keyURL(in.GetGroup(), in.GetKey(), nil), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("server returned: %v", res.StatusCode)
+	}
+	body, err = ioutil.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("reading response body: %v", err)
+	}
+	if err = proto.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("decoding response body: %v", err)
+	}
+	return nil
+}
+
+var _ PeerCompareAndSetter = (*httpGetter)(nil)
+
+func (h *httpGetter) CompareAndSet(in *cachepb.CompareAndSetRequest, out *cachepb.CompareAndSetResponse) error {
+	u := fmt.Sprintf("%v%v", h.baseURL, url.QueryEscape(in.GetGroup()))
+	body, err := proto.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPut, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("server returned: %v", res.StatusCode)
+	}
+	body, err = ioutil.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("reading response body: %v", err)
+	}
+	if err = proto.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("decoding response body: %v", err)
+	}
+	return nil
+}
+
 type rpcGetter struct {
 	baseRPCAddr string
 }
@@ -132,14 +581,127 @@ var _ PeerGetter = (*rpcGetter)(nil)
 
 func (r *rpcGetter) Get(in *cachepb.Request, out *cachepb.Response) error {
 	log.Println("get remote dcache rpc address ", r.baseRPCAddr)
-	conn, err := grpc.Dial(r.baseRPCAddr)
+	conn, err := grpc.Dial(r.baseRPCAddr, grpc.WithInsecure())
 	if err != nil {
-		return nil
+		return err
 	}
 	defer conn.Close()
 	cli := cachepb.NewGroupCacheClient(conn)
-	out, err = cli.Get(context.Background(), in) // rpc 调用
-	return err
+	resp, err := cli.Get(context.Background(), in) // rpc 调用
+	if err != nil {
+		return err
+	}
+	*out = *resp
+	return nil
+}
+
+var _ StreamPeerGetter = (*rpcGetter)(nil)
+
+func (r *rpcGetter) GetStream(in *cachepb.Request) (*cachepb.Response, [][]byte, error) {
+	conn, err := grpc.Dial(r.baseRPCAddr, grpc.WithInsecure())
+	if err != nil {
+		return nil, nil, err
+	}
+	defer conn.Close()
+	cli := cachepb.NewGroupCacheClient(conn)
+	stream, err := cli.GetStream(context.Background(), in)
+	if err != nil {
+		return nil, nil, err
+	}
+	var header *cachepb.Response
+	var chunks [][]byte
+	var size uint64
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if chunk.Header != nil {
+			header = chunk.Header
+		}
+		if len(chunk.Data) > 0 {
+			chunks = append(chunks, chunk.Data)
+			size += uint64(len(chunk.Data))
+		}
+	}
+	if header == nil {
+		return nil, nil, errors.New("missing stream header")
+	}
+	if size != header.GetSize() {
+		return nil, nil, fmt.Errorf("truncated stream: got %d of %d bytes", size, header.GetSize())
+	}
+	return header, chunks, nil
+}
+
+var _ PeerInvalidator = (*rpcGetter)(nil)
+
+func (r *rpcGetter) Invalidate(in *cachepb.InvalidateRequest, out *cachepb.InvalidateResponse) error {
+	conn, err := grpc.Dial(r.baseRPCAddr, grpc.WithInsecure())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	cli := cachepb.NewGroupCacheClient(conn)
+	resp, err := cli.Invalidate(context.Background(), in)
+	if err != nil {
+		return err
+	}
+	*out = *resp
+	return nil
+}
+
+var _ PeerPutter = (*rpcGetter)(nil)
+
+func (r *rpcGetter) Put(in *cachepb.PutRequest, out *cachepb.PutResponse) error {
+	conn, err := grpc.Dial(r.baseRPCAddr, grpc.WithInsecure())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	cli := cachepb.NewGroupCacheClient(conn)
+	resp, err := cli.Put(context.Background(), in)
+	if err != nil {
+		return err
+	}
+	*out = *resp
+	return nil
+}
+
+var _ PeerCompareAndSetter = (*rpcGetter)(nil)
+
+func (r *rpcGetter) CompareAndSet(in *cachepb.CompareAndSetRequest, out *cachepb.CompareAndSetResponse) error {
+	conn, err := grpc.Dial(r.baseRPCAddr, grpc.WithInsecure())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	cli := cachepb.NewGroupCacheClient(conn)
+	resp, err := cli.CompareAndSet(context.Background(), in)
+	if err != nil {
+		return err
+	}
+	*out = *resp
+	return nil
+}
+
+var _ BatchPeerGetter = (*rpcGetter)(nil)
+
+func (r *rpcGetter) BatchGet(in *cachepb.BatchRequest, out *cachepb.BatchResponse) error {
+	conn, err := grpc.Dial(r.baseRPCAddr, grpc.WithInsecure())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	cli := cachepb.NewGroupCacheClient(conn)
+	resp, err := cli.BatchGet(context.Background(), in)
+	if err != nil {
+		return err
+	}
+	*out = *resp
+	return nil
 }
 
 func (p *HTTPPool) Set(t GetterType, peers ...string) {
@@ -147,6 +709,7 @@ func (p *HTTPPool) Set(t GetterType, peers ...string) {
 	defer p.mu.Unlock()
 	p.peers = consistenthash.New(defaultReplicas, nil)
 	p.peers.Add(peers...)
+	p.addrs = append([]string(nil), peers...)
 	if p.getters == nil {
 		p.getters = make(map[string]PeerGetter, len(peers))
 	}
@@ -175,3 +738,18 @@ func (p *HTTPPool) PickPeer(key string) (PeerGetter, bool) {
 }
 
 var _ PeerPicker = (*HTTPPool)(nil)
+
+// ListPeers 返回除本机外的所有节点
+func (p *HTTPPool) ListPeers() []PeerGetter {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	peers := make([]PeerGetter, 0, len(p.addrs))
+	for _, addr := range p.addrs {
+		if addr != p.self {
+			peers = append(peers, p.getters[addr])
+		}
+	}
+	return peers
+}
+
+var _ PeerLister = (*HTTPPool)(nil)
@@ -0,0 +1,132 @@
+package dcache
+
+import (
+	"dcache/cachepb"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	invalidateRetries = 3                     // 广播失败时对每个节点的重试次数
+	invalidateBackoff = 50 * time.Millisecond // 第一次重试前的等待时间，之后每次翻倍
+)
+
+// Invalidate 删除本机缓存中的 key，包括负缓存和磁盘缓存，返回 key 是否在缓存中
+// 其他节点上的缓存不受影响，需要时使用 InvalidateEverywhere
+func (g *Group) Invalidate(key string) bool {
+	return g.invalidate(&cachepb.InvalidateRequest{Key: key}) > 0
+}
+
+// InvalidatePrefix 删除本机缓存中所有以 prefix 为前缀的key，返回删除的条数
+func (g *Group) InvalidatePrefix(prefix string) int {
+	return int(g.invalidate(&cachepb.InvalidateRequest{Key: prefix, Prefix: true}))
+}
+
+// InvalidateEverywhere 删除本机以及所有远程节点上 key 对应的缓存
+// 对每个节点至少成功发送一次，失败时重试，重试后仍然失败的节点通过错误返回
+func (g *Group) InvalidateEverywhere(key string) error {
+	return g.broadcast(&cachepb.InvalidateRequest{Group: g.name, Key: key})
+}
+
+// InvalidatePrefixEverywhere 删除本机以及所有远程节点上以 prefix 为前缀的缓存
+func (g *Group) InvalidatePrefixEverywhere(prefix string) error {
+	return g.broadcast(&cachepb.InvalidateRequest{Group: g.name, Key: prefix, Prefix: true})
+}
+
+// invalidate 处理删除请求，只删除本机的缓存，返回删除的条数
+func (g *Group) invalidate(req *cachepb.InvalidateRequest) int64 {
+	if req.GetGeneration() > 0 {
+		g.observeGeneration(req.GetGeneration())
+		return 0
+	}
+	if req.GetTag() != "" {
+		return g.invalidateTag(req.GetTag())
+	}
+	key := req.GetKey()
+	var n int64
+	if !req.GetPrefix() {
+		// 先作废租约，正在加载的旧值不会再放入缓存
+		g.leases.void(key)
+		if g.mainCache.remove(key) {
+			n++
+		}
+		if g.negCache != nil {
+			g.negCache.remove(key)
+		}
+		g.deleteFromDisk(key)
+		g.tags.remove(key)
+		return n
+	}
+
+	g.leases.voidPrefix(key)
+	n = int64(g.mainCache.removePrefix(key))
+	if g.negCache != nil {
+		g.negCache.removePrefix(key)
+	}
+	g.deletePrefixFromDisk(key)
+	g.tags.removePrefix(key)
+	return n
+}
+
+// broadcast 先删除本机的缓存，再并发发送给所有远程节点
+func (g *Group) broadcast(req *cachepb.InvalidateRequest) error {
+	g.invalidate(req)
+	pickers := g.peerPicker()
+	if pickers == nil {
+		return nil
+	}
+	lister, ok := pickers.(PeerLister)
+	if !ok {
+		return errors.New("dcache: peer picker can not list peers")
+	}
+
+	// 先找出不支持删除的节点，之后 failed 只在持有 mu 时修改
+	var (
+		invs   []PeerInvalidator
+		failed []string
+	)
+	for _, peer := range lister.ListPeers() {
+		if inv, ok := peer.(PeerInvalidator); ok {
+			invs = append(invs, inv)
+		} else {
+			failed = append(failed, fmt.Sprintf("%v: invalidate not supported", peer))
+		}
+	}
+
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	for _, inv := range invs {
+		wg.Add(1)
+		go func(inv PeerInvalidator) {
+			defer wg.Done()
+			if err := invalidatePeer(inv, req); err != nil {
+				mu.Lock()
+				failed = append(failed, err.Error())
+				mu.Unlock()
+			}
+		}(inv)
+	}
+	wg.Wait()
+	if len(failed) > 0 {
+		return fmt.Errorf("dcache: invalidate failed on %d peers: %s", len(failed), strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// invalidatePeer 向一个节点发送删除请求，失败时按指数退避重试
+func invalidatePeer(peer PeerInvalidator, req *cachepb.InvalidateRequest) error {
+	backoff := invalidateBackoff
+	for attempt := 0; ; attempt++ {
+		err := peer.Invalidate(req, &cachepb.InvalidateResponse{})
+		if err == nil || attempt == invalidateRetries {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
@@ -0,0 +1,155 @@
+package dcache
+
+import (
+	"dcache/cachepb"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGroup_Invalidate(t *testing.T) {
+	gc := NewRegistry().NewGroup("invalidate", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		if key == "missing" {
+			return nil, ErrNotFound
+		}
+		return []byte(key), nil
+	}), WithNegativeCache(time.Minute, 2<<10))
+	for _, k := range []string{"user:1", "user:2", "order:1", "missing"} {
+		gc.Get(k)
+	}
+
+	if !gc.Invalidate("order:1") || gc.Invalidate("order:1") {
+		t.Fatal("invalidate order:1 failed")
+	}
+	if n := gc.InvalidatePrefix("user:"); n != 2 || gc.mainCache.lru.Len() != 0 {
+		t.Fatalf("invalidate prefix removed %d", n)
+	}
+	gc.Invalidate("missing")
+	if gc.isNegative("missing") {
+		t.Fatal("negative cache should be invalidated")
+	}
+}
+
+// invalidateNode 一个使用 HTTP 通信的节点
+type invalidateNode struct {
+	pool  *HTTPPool
+	group *Group
+	srv   *httptest.Server
+}
+
+func newInvalidateNode(opts ...GroupOption) *invalidateNode {
+	return newClusterNode(GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}), opts...)
+}
+
+// newClusterNode 使用 getter 创建一个节点
+func newClusterNode(getter Getter, opts ...GroupOption) *invalidateNode {
+	n := &invalidateNode{}
+	r := NewRegistry()
+	n.group = r.NewGroup("invalidate-cluster", 2<<10, getter, opts...)
+	n.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n.pool.ServeHTTP(w, req)
+	}))
+	n.pool = r.NewHTTPPool(n.srv.URL)
+	r.RegisterPeers(n.pool)
+	return n
+}
+
+func TestGroup_InvalidateEverywhere(t *testing.T) {
+	nodes := []*invalidateNode{newInvalidateNode(), newInvalidateNode(), newInvalidateNode()}
+	var addrs []string
+	for _, n := range nodes {
+		defer n.srv.Close()
+		addrs = append(addrs, n.srv.URL)
+	}
+	for _, n := range nodes {
+		n.pool.Set(HttpGetter, addrs...)
+		// 每个节点都缓存了同样的key
+		n.group.populate("user:1", []byte("v1"), nil, 0, 0, 0)
+		n.group.populate("user:2", []byte("v2"), nil, 0, 0, 0)
+		n.group.populate("order:1", []byte("v1"), nil, 0, 0, 0)
+	}
+
+	if err := nodes[0].group.InvalidateEverywhere("order:1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := nodes[1].group.InvalidatePrefixEverywhere("user:"); err != nil {
+		t.Fatal(err)
+	}
+	for i, n := range nodes {
+		if l := n.group.mainCache.lru.Len(); l != 0 {
+			t.Fatalf("node %d still has %d entries", i, l)
+		}
+	}
+
+	// 节点不可用时返回错误
+	nodes[2].srv.Close()
+	if err := nodes[0].group.InvalidateEverywhere("user:1"); err == nil {
+		t.Fatal("invalidate should fail when a peer is down")
+	}
+}
+
+// flakyInvalidator 前 fails 次删除请求失败
+type flakyInvalidator struct {
+	fakePeer
+	fails int
+	reqs  []*cachepb.InvalidateRequest
+}
+
+func (p *flakyInvalidator) Invalidate(in *cachepb.InvalidateRequest, out *cachepb.InvalidateResponse) error {
+	p.reqs = append(p.reqs, in)
+	if len(p.reqs) <= p.fails {
+		return errors.New("unavailable")
+	}
+	return nil
+}
+
+type listPicker struct {
+	peers []PeerGetter
+}
+
+func (p *listPicker) PickPeer(key string) (PeerGetter, bool) {
+	return nil, false
+}
+
+func (p *listPicker) ListPeers() []PeerGetter {
+	return p.peers
+}
+
+func TestGroup_InvalidateRetry(t *testing.T) {
+	peer := &flakyInvalidator{fails: 1}
+	gc := NewRegistry().NewGroup("invalidate-retry", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}))
+	gc.RegisterPeers(&listPicker{peers: []PeerGetter{peer}})
+	if err := gc.InvalidatePrefixEverywhere("k"); err != nil {
+		t.Fatal(err)
+	}
+	if len(peer.reqs) != 2 || !peer.reqs[1].Prefix || peer.reqs[1].Group != "invalidate-retry" {
+		t.Fatalf("unexpected requests: %v", peer.reqs)
+	}
+
+	// 不支持删除的节点
+	gc = NewRegistry().NewGroup("invalidate-unsupported", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}))
+	gc.RegisterPeers(&listPicker{peers: []PeerGetter{&fakePeer{}}})
+	if err := gc.InvalidateEverywhere("k"); err == nil {
+		t.Fatal("invalidate should fail on unsupported peer")
+	}
+
+	// 失败的节点与不支持删除的节点同时存在
+	gc = NewRegistry().NewGroup("invalidate-mixed", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}))
+	gc.RegisterPeers(&listPicker{peers: []PeerGetter{
+		&flakyInvalidator{fails: invalidateRetries + 1}, &fakePeer{}, &fakePeer{}, &fakePeer{},
+	}})
+	if err := gc.InvalidateEverywhere("k"); err == nil || !strings.Contains(err.Error(), "4 peers") {
+		t.Fatalf("err = %v", err)
+	}
+}
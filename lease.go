@@ -0,0 +1,175 @@
+package dcache
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+租约（lease）：
+
+从源数据加载key 前先获取租约，加载期间 key 被删除、被 Set 写入或者代增加时租约作废，
+加载完成后租约已作废的值仍然返回给调用方，但不再放入缓存，避免旧值覆盖删除或写入的结果。
+加载期间的标签还不知道，按标签删除时只记录标签删除的序号，加载完成时记录的标签
+在获取租约之后被删除过的值同样不放入缓存。
+远程节点请求的key 在本机正在加载时不等待，响应中设置 LeaseHeld，
+请求方退避后重试，直到加载完成后拿到缓存的值，不会从本地的源数据重复加载。
+租约超过 leaseTimeout 没有释放时视为失效，避免请求方一直等待。
+*/
+
+const (
+	leaseBackoff    = 10 * time.Millisecond  // 第一次重试前的等待时间，之后每次翻倍
+	leaseMaxBackoff = 200 * time.Millisecond // 重试的最大等待时间
+	leaseTimeout    = 10 * time.Second       // 租约的最长有效时间
+)
+
+// errLeaseHeld 远程节点正在加载该key
+var errLeaseHeld = errors.New("dcache: lease held by another loader")
+
+// leaseTable 正在加载的key 的租约，零值可以直接使用
+type leaseTable struct {
+	mu     sync.Mutex
+	next   uint64
+	leases map[string]lease // key 对应的有效租约
+
+	tagSeq   uint64            // 按标签删除的次数
+	tagVoids map[string]uint64 // 标签最近一次被删除时的序号，只保留有效租约之后的
+}
+
+type lease struct {
+	token  uint64
+	start  time.Time
+	tagSeq uint64 // 获取租约时的 tagSeq
+}
+
+// acquire 获取 key 的租约，之前的租约作废
+func (t *leaseTable) acquire(key string) uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.leases == nil {
+		t.leases = make(map[string]lease)
+	}
+	t.next++
+	t.leases[key] = lease{token: t.next, start: time.Now(), tagSeq: t.tagSeq}
+	return t.next
+}
+
+// held 判断 key 是否正在加载，超时的租约不算
+func (t *leaseTable) held(key string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	l, ok := t.leases[key]
+	return ok && time.Since(l.start) < leaseTimeout
+}
+
+// commit 租约有效时执行 fn 并释放租约，返回租约是否有效
+// fn 在持有锁时执行，与作废租约互斥，作废后 fn 写入的值一定会被之后的删除操作删除
+func (t *leaseTable) commit(key string, token uint64, fn func()) bool {
+	return t.commitTags(key, token, nil, fn)
+}
+
+// commitTags 与 commit 相同，但 tags 中有标签在获取租约之后被删除时租约也无效
+func (t *leaseTable) commitTags(key string, token uint64, tags []string, fn func()) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	l, ok := t.leases[key]
+	if !ok || l.token != token {
+		return false
+	}
+	delete(t.leases, key)
+	voided := false
+	for _, tag := range tags {
+		if t.tagVoids[tag] > l.tagSeq {
+			voided = true
+		}
+	}
+	if len(t.leases) == 0 {
+		t.tagVoids = nil
+	}
+	if voided {
+		return false
+	}
+	fn()
+	return true
+}
+
+// voidTag 作废之后加载到的带有 tag 标签的值
+func (t *leaseTable) voidTag(tag string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.leases) == 0 {
+		// 没有正在加载的key
+		t.tagVoids = nil
+		return
+	}
+	t.tagSeq++
+	if t.tagVoids == nil {
+		t.tagVoids = make(map[string]uint64)
+	}
+	t.tagVoids[tag] = t.tagSeq
+	// 所有有效租约获取之前的删除不再需要
+	oldest := t.tagSeq
+	for _, l := range t.leases {
+		if l.tagSeq < oldest {
+			oldest = l.tagSeq
+		}
+	}
+	for tag, seq := range t.tagVoids {
+		if seq <= oldest {
+			delete(t.tagVoids, tag)
+		}
+	}
+}
+
+// void 作废 key 的租约
+func (t *leaseTable) void(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.leases, key)
+}
+
+// voidPrefix 作废所有以 prefix 为前缀的key 的租约
+func (t *leaseTable) voidPrefix(prefix string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for key := range t.leases {
+		if strings.HasPrefix(key, prefix) {
+			delete(t.leases, key)
+		}
+	}
+}
+
+// voidAll 作废所有租约
+func (t *leaseTable) voidAll() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.leases = nil
+	t.tagVoids = nil
+}
+
+// peerEntry 处理远程节点的请求，本机正在加载且没有缓存时返回 errLeaseHeld
+func (g *Group) peerEntry(key string) (entry, error) {
+	if g.leases.held(key) {
+		if _, ok := g.mainCache.getGen(key, g.Generation()); !ok {
+			return entry{}, errLeaseHeld
+		}
+	}
+	return g.getEntry(key)
+}
+
+// getFromPeerWait 从远程节点获取数据，远程节点正在加载时按指数退避重试，直到加载完成
+func (g *Group) getFromPeerWait(peer PeerGetter, key string) (entry, error) {
+	backoff := leaseBackoff
+	for {
+		e, err := g.getFromPeer(peer, key)
+		if err != errLeaseHeld {
+			return e, err
+		}
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > leaseMaxBackoff {
+			backoff = leaseMaxBackoff
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package dcache
+
+import (
+	"dcache/cachepb"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// slowGetter 加载时等待 release，started 通知已经开始加载
+type slowGetter struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func newSlowGetter() *slowGetter {
+	return &slowGetter{started: make(chan struct{}, 1), release: make(chan struct{})}
+}
+
+func (s *slowGetter) Get(key string) ([]byte, error) {
+	s.started <- struct{}{}
+	<-s.release
+	return []byte("old"), nil
+}
+
+// loadDuring 开始加载 key，加载期间执行 fn，返回加载的结果
+func loadDuring(t *testing.T, gc *Group, s *slowGetter, key string, fn func()) ByteView {
+	done := make(chan ByteView)
+	go func() {
+		v, err := gc.Get(key)
+		if err != nil {
+			t.Error(err)
+		}
+		done <- v
+	}()
+	<-s.started
+	fn()
+	close(s.release)
+	return <-done
+}
+
+func TestGroup_LeaseInvalidate(t *testing.T) {
+	s := newSlowGetter()
+	gc := NewRegistry().NewGroup("lease-invalidate", 2<<10, s)
+	v := loadDuring(t, gc, s, "k", func() {
+		if !gc.leases.held("k") {
+			t.Fatal("lease should be held during load")
+		}
+		gc.Invalidate("k")
+	})
+	// 加载到的值返回给调用方，但不放入缓存
+	if v.String() != "old" || gc.mainCache.bytes() != 0 || gc.Stats.StaleSets.Get() != 1 {
+		t.Fatalf("v = %q, bytes = %d, stale sets = %d", v, gc.mainCache.bytes(), gc.Stats.StaleSets.Get())
+	}
+	if gc.leases.held("k") {
+		t.Fatal("lease should be released")
+	}
+}
+
+func TestGroup_LeaseSet(t *testing.T) {
+	s := newSlowGetter()
+	gc := NewRegistry().NewGroup("lease-set", 2<<10, s)
+	loadDuring(t, gc, s, "k", func() {
+		gc.Set("k", []byte("new"))
+	})
+	if e, ok := gc.mainCache.get("k"); !ok || string(e.value.ByteSlice()) != "new" {
+		t.Fatal("set value should not be overwritten by stale load")
+	}
+
+	s = newSlowGetter()
+	gc = NewRegistry().NewGroup("lease-generation", 2<<10, s)
+	loadDuring(t, gc, s, "k", func() {
+		gc.BumpGeneration()
+	})
+	if gc.mainCache.bytes() != 0 {
+		t.Fatal("value loaded before generation bump should not be cached")
+	}
+}
+
+// leasePeer 前 held 次请求返回 LeaseHeld
+type leasePeer struct {
+	fakePeer
+	held int
+}
+
+func (p *leasePeer) Get(in *cachepb.Request, out *cachepb.Response) error {
+	p.calls++
+	if p.calls <= p.held {
+		*out = cachepb.Response{LeaseHeld: true}
+		return nil
+	}
+	*out = p.resp
+	return nil
+}
+
+func TestGroup_LeaseHeldByPeer(t *testing.T) {
+	loads := 0
+	newGroup := func(name string, peer PeerGetter) *Group {
+		gc := NewRegistry().NewGroup(name, 2<<10, GetterFunc(func(key string) ([]byte, error) {
+			loads++
+			return []byte("local"), nil
+		}))
+		gc.RegisterPeers(&fakePicker{peer: peer})
+		return gc
+	}
+
+	// 远程节点加载完成前退避重试，不从本地加载
+	value := []byte("remote")
+	peer := &leasePeer{fakePeer: fakePeer{resp: cachepb.Response{Value: value, Checksum: checksum(ByteView{b: value})}}, held: 2}
+	if v, err := newGroup("lease-peer", peer).Get("k"); err != nil || v.String() != "remote" {
+		t.Fatalf("v = %q, err = %v", v, err)
+	}
+	if peer.calls != 3 || loads != 0 {
+		t.Fatalf("peer calls = %d, loads = %d", peer.calls, loads)
+	}
+
+	// 远程节点加载较慢时一直等待，不从本地加载
+	peer = &leasePeer{fakePeer: fakePeer{resp: peer.resp}, held: 5}
+	if v, err := newGroup("lease-peer-slow", peer).Get("k"); err != nil || v.String() != "remote" {
+		t.Fatalf("v = %q, err = %v", v, err)
+	}
+	if peer.calls != 6 || loads != 0 {
+		t.Fatalf("peer calls = %d, loads = %d", peer.calls, loads)
+	}
+}
+
+func TestGroup_LeaseCluster(t *testing.T) {
+	s := newSlowGetter()
+	nodes := []*invalidateNode{newClusterNode(s), newClusterNode(s)}
+	var addrs []string
+	for _, n := range nodes {
+		defer n.srv.Close()
+		addrs = append(addrs, n.srv.URL)
+	}
+	for _, n := range nodes {
+		n.pool.Set(HttpGetter, addrs...)
+	}
+	// 找到一个属于 nodes[1] 的key
+	var key string
+	for i := 0; key == ""; i++ {
+		k := "key" + strconv.Itoa(i)
+		if _, ok := nodes[0].pool.PickPeer(k); ok {
+			key = k
+		}
+	}
+
+	// 所属的节点加载较慢时，其他节点等待加载完成，源数据只加载一次
+	done := make(chan ByteView)
+	loadDuring(t, nodes[1].group, s, key, func() {
+		go func() {
+			v, err := nodes[0].group.Get(key)
+			if err != nil {
+				t.Error(err)
+			}
+			done <- v
+		}()
+		time.Sleep(150 * time.Millisecond)
+	})
+	if v := <-done; v.String() != "old" {
+		t.Fatalf("v = %q", v)
+	}
+	if len(s.started) != 0 {
+		t.Fatal("key should be loaded once")
+	}
+	if _, ok := nodes[0].group.mainCache.get(key); ok {
+		t.Fatal("key should not be cached by non-owner")
+	}
+}
+
+func TestGroup_LeaseHeldResponse(t *testing.T) {
+	s := newSlowGetter()
+	gc := NewRegistry().NewGroup("lease-response", 2<<10, s)
+	loadDuring(t, gc, s, "k", func() {
+		// 本机正在加载时远程节点的请求不等待
+		resp, err := gc.response("k", 0)
+		if err != nil || !resp.LeaseHeld {
+			t.Fatalf("resp = %v, err = %v", resp, err)
+		}
+	})
+	if resp, err := gc.response("k", 0); err != nil || string(resp.Value) != "old" {
+		t.Fatalf("resp = %v, err = %v", resp, err)
+	}
+}
@@ -0,0 +1,92 @@
+package lru
+
+import "container/heap"
+
+/*
+GreedyDual-Size-Frequency 淘汰策略：
+
+	priority = clock + frequency * cost / size
+
+每次淘汰优先级最低的记录，并将 clock 更新为被淘汰记录的优先级，
+这样长期未被访问的记录的优先级会逐渐落后，最终被淘汰。
+加载代价高、访问频繁、占用空间小的记录会被保留更久。
+*/
+
+// gdsf 保存 GDSF 策略的状态
+type gdsf[K comparable, V any] struct {
+	clock float64
+	cost  func(key K, value V) float64
+	h     gdsfHeap[K, V]
+}
+
+// NewGDSF 创建一个使用 GDSF 策略淘汰记录的 cache
+// cost 计算一条记录的加载代价，为 nil 时每条记录的代价都为 1
+func NewGDSF[K comparable, V any](maxBytes int64, size func(K, V) int64, cost func(K, V) float64, onEvicted func(K, V, EvictReason)) *Cache[K, V] {
+	if cost == nil {
+		cost = func(K, V) float64 { return 1 }
+	}
+	c := NewCache(maxBytes, size, onEvicted)
+	c.gdsf = &gdsf[K, V]{cost: cost}
+	return c
+}
+
+// priority 计算记录的优先级
+func (p *gdsf[K, V]) priority(e *entry[K, V], size int64) float64 {
+	if size < 1 {
+		size = 1
+	}
+	return p.clock + float64(e.freq)*p.cost(e.key, e.value)/float64(size)
+}
+
+func (p *gdsf[K, V]) insert(e *entry[K, V], size int64) {
+	e.freq = 1
+	e.priority = p.priority(e, size)
+	heap.Push(&p.h, e)
+}
+
+// update 记录被访问或被更新后重新计算优先级
+func (p *gdsf[K, V]) update(e *entry[K, V], size int64) {
+	e.priority = p.priority(e, size)
+	heap.Fix(&p.h, e.index)
+}
+
+func (p *gdsf[K, V]) remove(e *entry[K, V]) {
+	heap.Remove(&p.h, e.index)
+}
+
+// victim 返回优先级最低的记录，同时推进 clock
+func (p *gdsf[K, V]) victim() *entry[K, V] {
+	if len(p.h) == 0 {
+		return nil
+	}
+	e := p.h[0]
+	p.clock = e.priority
+	return e
+}
+
+// gdsfHeap 按优先级排序的小顶堆
+type gdsfHeap[K comparable, V any] []*entry[K, V]
+
+func (h gdsfHeap[K, V]) Len() int           { return len(h) }
+func (h gdsfHeap[K, V]) Less(i, j int) bool { return h[i].priority < h[j].priority }
+func (h gdsfHeap[K, V]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *gdsfHeap[K, V]) Push(x interface{}) {
+	e := x.(*entry[K, V])
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *gdsfHeap[K, V]) Pop() interface{} {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	e.index = -1
+	*h = old[:n-1]
+	return e
+}
@@ -1,16 +1,50 @@
 package lru
 
-import "container/list"
+import (
+	"container/list"
+	"time"
+	"unsafe"
+)
 
-// Cache LRU cache
-type Cache struct {
+// Cache LRU cache，K 为任意可比较的键类型，V 为值类型
+type Cache[K comparable, V any] struct {
 	maxBytes int64      // 允许的最大内存
 	curBytes int64      //当前使用内存
 	ll       *list.List // 双向链表 链表中存储entry
 
-	cache map[string]*list.Element // 保存每个节点的地址，方便直接访问
+	cache map[K]*list.Element // 保存每个节点的地址，方便直接访问
+	size  func(key K, value V) int64
+	gdsf  *gdsf[K, V] // 不为 nil 时使用 GDSF 策略淘汰记录
 
-	OnEvicted func(key string, value Value) // 某条记录被移除时的回调函数
+	OnEvicted func(key K, value V, reason EvictReason) // 某条记录被移除时的回调函数
+	Now       func() time.Time                         // 判断过期使用的时钟，为 nil 时使用 time.Now
+}
+
+// EvictReason 记录被移除的原因
+type EvictReason int
+
+const (
+	EvictCapacity EvictReason = iota // 超过最大内存被淘汰
+	EvictExpired                     // 记录过期
+	EvictReplaced                    // 同一个key 写入了新值，旧值被替换
+	EvictDeleted                     // 调用 Delete 主动删除
+	EvictPurged                      // 调用 Purge 清空
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictCapacity:
+		return "capacity"
+	case EvictExpired:
+		return "expired"
+	case EvictReplaced:
+		return "replaced"
+	case EvictDeleted:
+		return "deleted"
+	case EvictPurged:
+		return "purged"
+	}
+	return "unknown"
 }
 
 // Value 存储类型
@@ -18,81 +52,300 @@ type Value interface {
 	Len() int
 }
 
-type entry struct {
-	key   string
-	value Value
+type entry[K comparable, V any] struct {
+	key    K
+	value  V
+	expire time.Time // 过期时间，零值表示永不过期
+
+	// GDSF 策略使用
+	freq     int64   // 访问次数
+	priority float64 // 优先级
+	index    int     // 在堆中的位置
 }
 
-// New maxBytes 允许的最大值 onEvicted 某个记录被删除时的回调函数
-func New(maxBytes int64, onEvicted func(string, Value)) *Cache {
-	return &Cache{
+// NewCache 创建一个泛型的LRU cache
+// maxBytes 允许的最大值，为0时不限制
+// size 计算一条记录占用的大小，为 nil 时每条记录按 1 计算，此时 maxBytes 相当于最大条目数
+// onEvicted 某个记录被删除时的回调函数
+func NewCache[K comparable, V any](maxBytes int64, size func(K, V) int64, onEvicted func(K, V, EvictReason)) *Cache[K, V] {
+	if size == nil {
+		size = func(K, V) int64 { return 1 }
+	}
+	return &Cache[K, V]{
 		maxBytes:  maxBytes,
 		curBytes:  0,
 		ll:        list.New(),
-		cache:     make(map[string]*list.Element),
+		cache:     make(map[K]*list.Element),
+		size:      size,
 		OnEvicted: onEvicted,
 	}
 }
 
+// New maxBytes 允许的最大值 onEvicted 某个记录被删除时的回调函数
+// 键为 string，值为 Value，大小按 len(key)+value.Len() 计算
+// onEvicted 不区分移除原因，需要原因时使用 NewCache
+func New(maxBytes int64, onEvicted func(string, Value)) *Cache[string, Value] {
+	var cb func(string, Value, EvictReason)
+	if onEvicted != nil {
+		cb = func(key string, value Value, _ EvictReason) {
+			onEvicted(key, value)
+		}
+	}
+	return NewCache(maxBytes, func(key string, value Value) int64 {
+		return int64(len(key)) + int64(value.Len())
+	}, cb)
+}
+
+// Overhead 除键和值本身之外，每条记录额外占用的内存估算值，
+// 包括链表节点、entry 结构体以及 map 中的一个槽位（键和指向链表节点的指针）
+// 不包括键和值引用的堆内存，如 string 的内容
+func Overhead[K comparable, V any]() int64 {
+	var (
+		ele list.Element
+		e   entry[K, V]
+		key K
+		ptr *list.Element
+	)
+	return int64(unsafe.Sizeof(ele) + unsafe.Sizeof(e) + unsafe.Sizeof(key) + unsafe.Sizeof(ptr))
+}
+
 // Get 从map中查询对应节点，将该节点移至队首
-func (c *Cache) Get(key string) (value Value, ok bool) {
-	if ele, ok := c.cache[key]; ok {
+func (c *Cache[K, V]) Get(key K) (value V, ok bool) {
+	if ele, ok := c.lookup(key); ok {
 		// 将最新访问的放在队首
 		c.ll.MoveToFront(ele)
-		return ele.Value.(*entry).value, ok
+		e := ele.Value.(*entry[K, V])
+		if c.gdsf != nil {
+			e.freq++
+			c.gdsf.update(e, c.size(e.key, e.value))
+		}
+		return e.value, ok
+	}
+	return
+}
+
+// Peek 查询记录但不改变其访问顺序
+func (c *Cache[K, V]) Peek(key K) (value V, ok bool) {
+	if ele, ok := c.lookup(key); ok {
+		return ele.Value.(*entry[K, V]).value, ok
+	}
+	return
+}
+
+// Contains 判断记录是否存在，不改变其访问顺序
+func (c *Cache[K, V]) Contains(key K) bool {
+	_, ok := c.lookup(key)
+	return ok
+}
+
+// Expire 返回记录的过期时间，零值表示永不过期
+func (c *Cache[K, V]) Expire(key K) (expire time.Time, ok bool) {
+	if ele, ok := c.lookup(key); ok {
+		return ele.Value.(*entry[K, V]).expire, ok
 	}
 	return
 }
 
-func (c *Cache) Remove() {
+// lookup 查询节点，已过期的记录会被移除
+func (c *Cache[K, V]) lookup(key K) (*list.Element, bool) {
+	ele, ok := c.cache[key]
+	if !ok {
+		return nil, false
+	}
+	if c.expired(ele.Value.(*entry[K, V]), c.now()) {
+		c.removeElement(ele, EvictExpired)
+		return nil, false
+	}
+	return ele, true
+}
+
+func (c *Cache[K, V]) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+func (c *Cache[K, V]) expired(e *entry[K, V], now time.Time) bool {
+	return !e.expire.IsZero() && !now.Before(e.expire)
+}
+
+// Remove 按淘汰策略移除一条记录
+// LRU 策略下移除最久未访问的记录，GDSF 策略下移除优先级最低的记录
+func (c *Cache[K, V]) Remove() {
+	if c.gdsf != nil {
+		if e := c.gdsf.victim(); e != nil {
+			c.removeElement(c.cache[e.key], EvictCapacity)
+		}
+		return
+	}
 	// 获取队尾元素
 	ele := c.ll.Back()
 	if ele != nil {
-		c.ll.Remove(ele)
-		e := ele.Value.(*entry)
-		delete(c.cache, e.key)
-		// 删除后，当前存储字节数也相应减少
-		c.curBytes -= int64(len(e.key)) + int64(e.value.Len())
+		c.removeElement(ele, EvictCapacity)
+	}
+}
 
-		// 如果注册了回调函数，则处理回调
-		if c.OnEvicted != nil {
-			c.OnEvicted(e.key, e.value)
+// Delete 移除指定的记录，返回记录是否存在
+func (c *Cache[K, V]) Delete(key K) bool {
+	if ele, ok := c.cache[key]; ok {
+		c.removeElement(ele, EvictDeleted)
+		return true
+	}
+	return false
+}
+
+// Purge 清空所有记录，每条记录都会触发回调
+func (c *Cache[K, V]) Purge() {
+	for ele := c.ll.Back(); ele != nil; ele = c.ll.Back() {
+		c.removeElement(ele, EvictPurged)
+	}
+}
+
+// RemoveExpired 移除所有已过期的记录，返回移除的条数
+func (c *Cache[K, V]) RemoveExpired() int {
+	now := c.now()
+	n := 0
+	for ele := c.ll.Back(); ele != nil; {
+		prev := ele.Prev()
+		if c.expired(ele.Value.(*entry[K, V]), now) {
+			c.removeElement(ele, EvictExpired)
+			n++
 		}
+		ele = prev
+	}
+	return n
+}
+
+func (c *Cache[K, V]) removeElement(ele *list.Element, reason EvictReason) {
+	c.ll.Remove(ele)
+	e := ele.Value.(*entry[K, V])
+	delete(c.cache, e.key)
+	if c.gdsf != nil {
+		c.gdsf.remove(e)
+	}
+	// 删除后，当前存储字节数也相应减少
+	c.curBytes -= c.size(e.key, e.value)
+
+	// 如果注册了回调函数，则处理回调
+	if c.OnEvicted != nil {
+		c.OnEvicted(e.key, e.value, reason)
 	}
 }
 
 // Add 添加记录
-func (c *Cache) Add(key string, value Value) {
+func (c *Cache[K, V]) Add(key K, value V) {
+	c.AddWithExpire(key, value, time.Time{})
+}
+
+// AddWithExpire 添加记录，记录在 expire 之后过期，零值表示永不过期
+func (c *Cache[K, V]) AddWithExpire(key K, value V, expire time.Time) {
 	if ele, ok := c.cache[key]; ok {
 		// 存在相同的key ，则直接更新值
-		e := ele.Value.(*entry)
+		e := ele.Value.(*entry[K, V])
 		// 同时将这个元素移至队首
 		c.ll.MoveToFront(ele)
 		// 更新字节大小
-		c.curBytes += int64(value.Len()) - int64(e.value.Len())
+		c.curBytes += c.size(key, value) - c.size(e.key, e.value)
 		// 更新值
+		old := e.value
 		e.value = value
+		e.expire = expire
+		if c.gdsf != nil {
+			c.gdsf.update(e, c.size(key, value))
+		}
+		if c.OnEvicted != nil {
+			c.OnEvicted(key, old, EvictReplaced)
+		}
 
 	} else {
-		e := &entry{
-			key:   key,
-			value: value,
+		e := &entry[K, V]{
+			key:    key,
+			value:  value,
+			expire: expire,
 		}
 		// 最新元素
 		c.cache[key] = c.ll.PushFront(e)
+		if c.gdsf != nil {
+			c.gdsf.insert(e, c.size(key, value))
+		}
 		// 更新存储大小
-		c.curBytes += int64(len(key)) + int64(value.Len())
+		c.curBytes += c.size(key, value)
 	}
 
-	if c.maxBytes != 0 && c.curBytes > c.maxBytes {
-		// 超过了最大内存设置，移除
+	c.evict()
+}
+
+// evict 超过了最大内存设置时，按淘汰策略移除记录，返回移除的条数
+func (c *Cache[K, V]) evict() int {
+	n := 0
+	for c.maxBytes != 0 && c.curBytes > c.maxBytes && c.ll.Len() > 0 {
 		c.Remove()
+		n++
+	}
+	return n
+}
+
+// Resize 修改允许的最大内存，必要时淘汰记录，返回淘汰的条数
+func (c *Cache[K, V]) Resize(maxBytes int64) int {
+	c.maxBytes = maxBytes
+	return c.evict()
+}
+
+// Keys 按访问顺序返回所有未过期的key，最近访问的在前
+func (c *Cache[K, V]) Keys() []K {
+	keys := make([]K, 0, c.ll.Len())
+	c.Range(func(key K, _ V) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return keys
+}
+
+// Range 按访问顺序遍历未过期的记录，最近访问的在前，fn 返回 false 时停止遍历
+// 遍历过程中不能修改 cache
+func (c *Cache[K, V]) Range(fn func(key K, value V) bool) {
+	now := c.now()
+	for ele := c.ll.Front(); ele != nil; ele = ele.Next() {
+		e := ele.Value.(*entry[K, V])
+		if c.expired(e, now) {
+			continue
+		}
+		if !fn(e.key, e.value) {
+			return
+		}
 	}
 }
 
-func (c *Cache) Len() int {
+// RangeOldest 从最久未访问的记录开始遍历未过期的记录，同时提供过期时间，
+// 按遍历顺序重新添加可以还原访问顺序，fn 返回 false 时停止遍历
+// 遍历过程中不能修改 cache
+func (c *Cache[K, V]) RangeOldest(fn func(key K, value V, expire time.Time) bool) {
+	now := c.now()
+	for ele := c.ll.Back(); ele != nil; ele = ele.Prev() {
+		e := ele.Value.(*entry[K, V])
+		if c.expired(e, now) {
+			continue
+		}
+		if !fn(e.key, e.value, e.expire) {
+			return
+		}
+	}
+}
+
+func (c *Cache[K, V]) Len() int {
 	if c.ll.Len() != len(c.cache) {
 		panic("map与list大小不一致")
 	}
 	return c.ll.Len()
 }
+
+// Bytes 当前使用的内存
+func (c *Cache[K, V]) Bytes() int64 {
+	return c.curBytes
+}
+
+// MaxBytes 允许的最大内存
+func (c *Cache[K, V]) MaxBytes() int64 {
+	return c.maxBytes
+}
@@ -1,7 +1,9 @@
 package lru
 
 import (
+	"reflect"
 	"testing"
+	"time"
 )
 
 type String string
@@ -51,3 +53,209 @@ func TestCache_OnEvicted(t *testing.T) {
 		t.Fatal("callback failed")
 	}
 }
+
+func TestCache_Generic(t *testing.T) {
+	// 不指定 size 时按条目数淘汰
+	lru := NewCache[int, []byte](2, nil, nil)
+	lru.Add(1, []byte("a"))
+	lru.Add(2, []byte("b"))
+	lru.Get(1)
+	lru.Add(3, []byte("c")) // 1 刚被访问过，因此淘汰 2
+
+	if _, ok := lru.Get(2); ok || lru.Len() != 2 {
+		t.Fatal("Remove 2 failed")
+	}
+	if v, ok := lru.Get(1); !ok || string(v) != "a" {
+		t.Fatal("cache get 1 error")
+	}
+
+	// 自定义 size
+	sized := NewCache(10, func(k int, v string) int64 { return int64(len(v)) }, nil)
+	sized.Add(1, "12345")
+	sized.Add(2, "123456")
+	if _, ok := sized.Get(1); ok || sized.Len() != 1 {
+		t.Fatal("sized cache evict failed")
+	}
+}
+
+func TestCache_PeekContains(t *testing.T) {
+	lru := NewCache[string, int](2, nil, nil)
+	lru.Add("a", 1)
+	lru.Add("b", 2)
+	// Peek 不改变访问顺序，因此添加 c 时仍然淘汰 a
+	if v, ok := lru.Peek("a"); !ok || v != 1 {
+		t.Fatal("peek a failed")
+	}
+	if !lru.Contains("b") || lru.Contains("c") {
+		t.Fatal("contains failed")
+	}
+	lru.Add("c", 3)
+	if lru.Contains("a") {
+		t.Fatal("peek should not promote a")
+	}
+}
+
+func TestCache_Delete(t *testing.T) {
+	keys := make([]string, 0)
+	lru := New(0, func(key string, value Value) {
+		keys = append(keys, key)
+	})
+	lru.Add("key1", String("value1"))
+	lru.Add("key2", String("value2"))
+
+	if !lru.Delete("key1") || lru.Delete("key1") {
+		t.Fatal("delete key1 failed")
+	}
+	if _, ok := lru.Get("key1"); ok || lru.Len() != 1 || lru.Bytes() != int64(len("key2value2")) {
+		t.Fatal("key1 still in cache")
+	}
+	if len(keys) != 1 || keys[0] != "key1" {
+		t.Fatal("callback failed")
+	}
+}
+
+func TestCache_KeysRange(t *testing.T) {
+	lru := NewCache[string, int](0, nil, nil)
+	lru.Add("a", 1)
+	lru.Add("b", 2)
+	lru.Add("c", 3)
+	lru.Get("a")
+
+	if keys := lru.Keys(); !reflect.DeepEqual(keys, []string{"a", "c", "b"}) {
+		t.Fatalf("keys order error: %v", keys)
+	}
+
+	var visited []int
+	lru.Range(func(key string, value int) bool {
+		visited = append(visited, value)
+		return len(visited) < 2
+	})
+	if !reflect.DeepEqual(visited, []int{1, 3}) {
+		t.Fatalf("range error: %v", visited)
+	}
+
+	var oldest []string
+	lru.RangeOldest(func(key string, _ int, _ time.Time) bool {
+		oldest = append(oldest, key)
+		return true
+	})
+	if !reflect.DeepEqual(oldest, []string{"b", "c", "a"}) {
+		t.Fatalf("range oldest error: %v", oldest)
+	}
+}
+
+func TestCache_Resize(t *testing.T) {
+	lru := New(0, nil)
+	lru.Add("k1", String("v1"))
+	lru.Add("k2", String("v2"))
+	lru.Add("k3", String("v3"))
+	if lru.Bytes() != 12 {
+		t.Fatalf("bytes = %d, want 12", lru.Bytes())
+	}
+
+	// 缩小容量后淘汰最久未访问的记录
+	if n := lru.Resize(8); n != 1 || lru.Contains("k1") || lru.MaxBytes() != 8 {
+		t.Fatal("resize failed")
+	}
+	if n := lru.Resize(100); n != 0 || lru.Len() != 2 {
+		t.Fatal("grow failed")
+	}
+}
+
+func TestCache_Purge(t *testing.T) {
+	evicted := 0
+	lru := New(0, func(key string, value Value) {
+		evicted++
+	})
+	lru.Add("k1", String("v1"))
+	lru.Add("k2", String("v2"))
+	lru.Purge()
+	if lru.Len() != 0 || lru.Bytes() != 0 || evicted != 2 {
+		t.Fatal("purge failed")
+	}
+}
+
+func TestCache_EvictReason(t *testing.T) {
+	reasons := make(map[string]EvictReason)
+	lru := NewCache(10, func(k, v string) int64 { return int64(len(v)) }, func(key, value string, reason EvictReason) {
+		reasons[key+"="+value] = reason
+	})
+	lru.Add("a", "12345")
+	lru.Add("a", "1234") // 替换旧值
+	lru.Add("b", "12345")
+	lru.Add("c", "12") // 超过容量，淘汰 a
+	lru.Delete("b")
+	lru.Purge()
+
+	want := map[string]EvictReason{
+		"a=12345": EvictReplaced,
+		"a=1234":  EvictCapacity,
+		"b=12345": EvictDeleted,
+		"c=12":    EvictPurged,
+	}
+	if !reflect.DeepEqual(reasons, want) {
+		t.Fatalf("reasons = %v, want %v", reasons, want)
+	}
+}
+
+func TestCache_Expire(t *testing.T) {
+	now := time.Unix(1000, 0)
+	var expired []string
+	lru := NewCache[string, int](0, nil, func(key string, value int, reason EvictReason) {
+		if reason == EvictExpired {
+			expired = append(expired, key)
+		}
+	})
+	lru.Now = func() time.Time { return now }
+
+	lru.AddWithExpire("a", 1, now.Add(time.Second))
+	lru.AddWithExpire("b", 2, now.Add(2*time.Second))
+	lru.Add("c", 3)
+
+	if _, ok := lru.Get("a"); !ok {
+		t.Fatal("a should not expire yet")
+	}
+	now = now.Add(time.Second)
+	if _, ok := lru.Get("a"); ok || !reflect.DeepEqual(expired, []string{"a"}) {
+		t.Fatal("a should expire")
+	}
+
+	now = now.Add(time.Second)
+	if keys := lru.Keys(); !reflect.DeepEqual(keys, []string{"c"}) {
+		t.Fatalf("keys = %v, want [c]", keys)
+	}
+	if n := lru.RemoveExpired(); n != 1 || lru.Len() != 1 {
+		t.Fatal("remove expired failed")
+	}
+}
+
+func TestGDSF(t *testing.T) {
+	costs := map[string]float64{"slow": 100, "a": 1, "b": 1, "c": 2}
+	lru := NewGDSF[string, int](3, nil, func(key string, value int) float64 {
+		return costs[key]
+	}, nil)
+
+	lru.Add("slow", 1)
+	lru.Add("a", 1)
+	lru.Add("b", 1)
+	lru.Get("a")
+	lru.Get("a")
+	// slow 最久未访问，但加载代价最高；b 代价低且只访问过一次，因此被淘汰
+	lru.Add("c", 1)
+	if !lru.Contains("slow") || !lru.Contains("a") || lru.Contains("b") {
+		t.Fatalf("gdsf evict error, keys: %v", lru.Keys())
+	}
+
+	lru.Delete("slow")
+	lru.Purge()
+	if lru.Len() != 0 || len(lru.gdsf.h) != 0 {
+		t.Fatal("gdsf heap not cleared")
+	}
+}
+
+func TestOverhead(t *testing.T) {
+	small, large := Overhead[int8, int8](), Overhead[string, [64]byte]()
+	if small <= 0 || large-small < 64 {
+		t.Fatalf("overhead small = %d, large = %d", small, large)
+	}
+}
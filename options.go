@@ -0,0 +1,135 @@
+package dcache
+
+import (
+	"dcache/disk"
+	"dcache/lru"
+	"time"
+)
+
+// GroupOption 创建 Group 时的可选配置
+type GroupOption func(g *Group)
+
+// EvictionHook 记录从 Group 的缓存中移除时的回调
+// 回调在释放缓存锁之后、移除记录的调用返回之前执行，可以在回调中访问该 Group
+type EvictionHook func(key string, value ByteView, reason lru.EvictReason)
+
+// WithEvictionHook 注册记录被移除时的回调，可以多次注册
+func WithEvictionHook(hook EvictionHook) GroupOption {
+	return func(g *Group) {
+		g.evictionHooks = append(g.evictionHooks, hook)
+	}
+}
+
+// WithCostAwareEviction 使用 GreedyDual-Size-Frequency 策略淘汰记录，
+// 记录保留的时间与 (加载耗时 × 访问次数) / 大小 成正比，加载慢的记录会被保留更久
+func WithCostAwareEviction() GroupOption {
+	return func(g *Group) {
+		g.mainCache.costAware = true
+	}
+}
+
+// WithNegativeCache 开启负缓存：Getter 返回 ErrNotFound 时，在 ttl 内直接返回 ErrNotFound，
+// 不再调用 Getter，cacheBytes 为负缓存允许使用的最大内存
+func WithNegativeCache(ttl time.Duration, cacheBytes int64) GroupOption {
+	return func(g *Group) {
+		g.negCache = &cache{cacheBytes: cacheBytes}
+		g.negativeTTL = ttl
+	}
+}
+
+// WithTTL 记录加载后在 ttl 内是新鲜的，过期后重新加载
+func WithTTL(ttl time.Duration) GroupOption {
+	return func(g *Group) {
+		g.ttl = ttl
+	}
+}
+
+// WithRefreshAhead 记录的剩余新鲜时间低于 ttl 的 fraction 时，在后台提前刷新，
+// 调用方仍然直接拿到当前的值，需要同时设置 WithTTL
+func WithRefreshAhead(fraction float64) GroupOption {
+	return func(g *Group) {
+		g.refreshAhead = fraction
+	}
+}
+
+// WithStaleWhileRevalidate 记录过期后的 window 内仍然返回旧值，同时在后台刷新
+func WithStaleWhileRevalidate(window time.Duration) GroupOption {
+	return func(g *Group) {
+		g.staleWhileRevalidate = window
+	}
+}
+
+// WithStaleIfError 记录过期后的 window 内，重新加载失败时返回旧值
+func WithStaleIfError(window time.Duration) GroupOption {
+	return func(g *Group) {
+		g.staleIfError = window
+	}
+}
+
+// WithCompression 压缩不小于 threshold 字节的值，缓存中保存压缩后的数据，
+// 占用的内存按压缩后的大小计算，传给远程节点的也是压缩后的数据，c 同时通过 RegisterCompressor 注册
+func WithCompression(c Compressor, threshold int) GroupOption {
+	RegisterCompressor(c)
+	return func(g *Group) {
+		g.compressor = c
+		g.compressThreshold = threshold
+	}
+}
+
+// WithChunking 超过 threshold 字节的值分块存储，每块 chunkSize 字节，
+// 避免为大的值分配连续的内存
+func WithChunking(threshold, chunkSize int) GroupOption {
+	return func(g *Group) {
+		g.chunkThreshold = threshold
+		g.chunkSize = chunkSize
+	}
+}
+
+// WithWeight 设置共享内存上限时 Group 的权重，默认为 1，
+// 权重越高，超过上限时越晚被淘汰
+func WithWeight(weight float64) GroupOption {
+	return func(g *Group) {
+		g.weight = weight
+	}
+}
+
+// WithSnapshot 创建 Group 时从快照文件 path 恢复缓存，之后每隔 interval 保存一次快照，
+// 删除 Group 时也会保存，interval 为0时只在删除时保存
+func WithSnapshot(path string, interval time.Duration) GroupOption {
+	return func(g *Group) {
+		g.snapshotPath = path
+		g.snapshotInterval = interval
+	}
+}
+
+// WithDiskTier 使用磁盘缓存作为二级缓存，因容量被淘汰的记录由后台写入 s，
+// 本地缓存未命中时先查询 s，再查询远程节点或源数据
+// s 由调用方打开和关闭，不同的 Group 不能共用同一个 s
+func WithDiskTier(s *disk.Store) GroupOption {
+	return func(g *Group) {
+		g.disk = s
+		g.spills = newSpillQueue(s)
+		s.OnEvicted = func(key string) {
+			g.tags.remove(key)
+		}
+	}
+}
+
+// WithWriteThrough Set 时先同步写入 s，写入成功后再更新缓存
+func WithWriteThrough(s Setter) GroupOption {
+	return func(g *Group) {
+		g.setter = s
+		g.writer = nil
+	}
+}
+
+// WithWriteBehind Set 时先更新缓存，再由后台写入 s：
+// 同一个key 的多次写入只写入最后一次，凑满 batchSize 个key 或者每隔 interval 写入一批，
+// s 实现了 BatchSetter 时一批只调用一次；写入失败时重试，
+// 写入成功之前缓存未命中时返回待写入的值，删除 Group 时写入剩余的数据
+func WithWriteBehind(s Setter, batchSize int, interval time.Duration) GroupOption {
+	return func(g *Group) {
+		g.writer = newWriteBehind(s, batchSize, interval, &g.Stats)
+		g.setter = nil
+	}
+}
@@ -11,9 +11,40 @@ type PeerGetter interface {
 	Get(in *cachepb.Request, out *cachepb.Response) error
 }
 
+// BatchPeerGetter 支持批量获取的远程节点
+type BatchPeerGetter interface {
+	PeerGetter
+	BatchGet(in *cachepb.BatchRequest, out *cachepb.BatchResponse) error
+}
+
+// StreamPeerGetter 支持分块传输的远程节点，大的值不需要一次性读入连续的内存
+type StreamPeerGetter interface {
+	PeerGetter
+	// GetStream 返回除 value 外的响应信息，以及分块读取的 value
+	GetStream(in *cachepb.Request) (header *cachepb.Response, chunks [][]byte, err error)
+}
+
 type GetterType int
 
 const (
 	HttpGetter GetterType = iota
 	RpcGetter
 )
+
+// PeerLister 可以列出所有远程节点，用于向所有节点广播
+type PeerLister interface {
+	// ListPeers 返回除本机外的所有节点
+	ListPeers() []PeerGetter
+}
+
+// PeerPutter 支持写入的远程节点，Group.Set 发送给 key 所属的节点执行
+type PeerPutter interface {
+	PeerGetter
+	Put(in *cachepb.PutRequest, out *cachepb.PutResponse) error
+}
+
+// PeerInvalidator 支持删除缓存的远程节点
+type PeerInvalidator interface {
+	PeerGetter
+	Invalidate(in *cachepb.InvalidateRequest, out *cachepb.InvalidateResponse) error
+}
@@ -0,0 +1,139 @@
+package dcache
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrGroupExists 同名的 Group 已经存在
+var ErrGroupExists = errors.New("dcache: group already exists")
+
+// Registry 管理一组 Group 以及它们使用的节点，
+// 同一个进程中可以有多个相互独立的 Registry
+type Registry struct {
+	used   int64             // 所有 Group 使用的内存之和，只能原子地访问，放在开头保证 64 位对齐
+	budget int64             // 所有 Group 共享的内存上限，为0时不限制，只能原子地访问
+	mu     sync.RWMutex      // 一个读写锁
+	groups map[string]*Group // 缓存所有的Group
+	peers  atomic.Value      // Group 默认使用的节点，类型为 pickerValue
+
+	newGroupHooks []func(*Group) // 创建 Group 时的回调
+
+	budgetMu sync.Mutex // 保护 Group 的命中采样，同一时间只有一个淘汰过程
+}
+
+// DefaultRegistry 包级别的 NewGroup、GetGroup 等函数使用的 Registry
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry 创建一个新的 Registry
+func NewRegistry() *Registry {
+	return &Registry{groups: make(map[string]*Group)}
+}
+
+// NewGroup 新建一个新的Group，然后放入 Registry 中
+// 已经存在同名的 Group 时会覆盖，被覆盖的 Group 与 DeleteGroup 一样被关闭，需要报错时使用 CreateGroup
+func (r *Registry) NewGroup(name string, cacheBytes int64, getter Getter, opts ...GroupOption) *Group {
+	g, _ := r.addGroup(newGroup(name, cacheBytes, getter, opts...), true)
+	return g
+}
+
+// CreateGroup 与 NewGroup 相同，但同名的 Group 已经存在时返回 ErrGroupExists
+func (r *Registry) CreateGroup(name string, cacheBytes int64, getter Getter, opts ...GroupOption) (*Group, error) {
+	return r.addGroup(newGroup(name, cacheBytes, getter, opts...), false)
+}
+
+func (r *Registry) addGroup(g *Group, overwrite bool) (*Group, error) {
+	r.mu.Lock()
+	old, ok := r.groups[g.name]
+	if ok && !overwrite {
+		r.mu.Unlock()
+		return nil, ErrGroupExists
+	}
+	g.registry = r
+	g.mainCache.track(&r.used)
+	r.groups[g.name] = g
+	hooks := r.newGroupHooks
+	r.mu.Unlock()
+
+	// 被覆盖的 Group 先关闭并保存快照，新的 Group 再从快照恢复
+	// 没有被接受的 Group 不会启动后台任务，不会覆盖同名 Group 的快照
+	if old != nil {
+		old.close()
+	}
+	g.start()
+
+	// 释放锁后再调用回调，回调中可以访问 Registry
+	for _, hook := range hooks {
+		hook(g)
+	}
+	return g, nil
+}
+
+// GetGroup 获取一个指定的Group
+func (r *Registry) GetGroup(name string) *Group {
+	r.mu.RLock()
+	g := r.groups[name]
+	r.mu.RUnlock()
+	return g
+}
+
+// DeleteGroup 删除指定的Group并清空其缓存，返回Group是否存在
+// 删除后已经拿到该Group的调用方仍可以使用，但不会再被远程节点访问到
+func (r *Registry) DeleteGroup(name string) bool {
+	r.mu.Lock()
+	g, ok := r.groups[name]
+	delete(r.groups, name)
+	r.mu.Unlock()
+
+	if ok {
+		g.close()
+	}
+	return ok
+}
+
+// ListGroups 按名字排序返回所有的Group
+func (r *Registry) ListGroups() []*Group {
+	r.mu.RLock()
+	list := make([]*Group, 0, len(r.groups))
+	for _, g := range r.groups {
+		list = append(list, g)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].name < list[j].name
+	})
+	return list
+}
+
+// RegisterNewGroupHook 注册创建 Group 时的回调，可以注册多个
+func (r *Registry) RegisterNewGroupHook(fn func(*Group)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.newGroupHooks = append(r.newGroupHooks, fn)
+}
+
+// RegisterPeers 设置 Registry 中所有 Group 使用的节点，只能调用一次，
+// 已经存在以及之后新建的 Group 没有自己注册节点时都会使用 picker，可以在 Group 处理请求时调用
+func (r *Registry) RegisterPeers(picker PeerPicker) {
+	if !r.peers.CompareAndSwap(nil, pickerValue{picker}) {
+		panic("dcache: Registry.RegisterPeers called more than once")
+	}
+}
+
+// peerPicker 返回 Registry 的节点，没有注册时返回 nil
+func (r *Registry) peerPicker() PeerPicker {
+	if p, ok := r.peers.Load().(pickerValue); ok {
+		return p.PeerPicker
+	}
+	return nil
+}
+
+// NewHTTPPool 创建一个只访问该 Registry 中 Group 的 HTTPPool
+func (r *Registry) NewHTTPPool(self string) *HTTPPool {
+	p := NewHTTPPool(self)
+	p.registry = r
+	return p
+}
@@ -0,0 +1,82 @@
+package dcache
+
+import (
+	"dcache/cachepb"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	r1, r2 := NewRegistry(), NewRegistry()
+	g1 := r1.NewGroup("scores", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("r1-" + key), nil
+	}))
+	g2 := r2.NewGroup("scores", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte("r2-" + key), nil
+	}))
+
+	if r1.GetGroup("scores") != g1 || r2.GetGroup("scores") != g2 || GetGroup("scores") != nil {
+		t.Fatal("registries should be independent")
+	}
+	if v, _ := g1.Get("Tom"); v.String() != "r1-Tom" {
+		t.Fatalf("got %s", v)
+	}
+	if v, _ := g2.Get("Tom"); v.String() != "r2-Tom" {
+		t.Fatalf("got %s", v)
+	}
+
+	// HTTPPool 只访问所属 Registry 中的 Group
+	srv := httptest.NewServer(r2.NewHTTPPool("self"))
+	defer srv.Close()
+	out := &cachepb.Response{}
+	getter := &httpGetter{baseURL: srv.URL + defaultBasePath}
+	if err := getter.Get(&cachepb.Request{Group: "scores", Key: "Sam"}, out); err != nil || string(out.Value) != "r2-Sam" {
+		t.Fatalf("got %s, %v", out.Value, err)
+	}
+}
+
+func TestRegistry_RegisterPeers(t *testing.T) {
+	r := NewRegistry()
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	})
+	before := r.NewGroup("before", 2<<10, getter)
+	picker := &fakePicker{}
+	r.RegisterPeers(picker)
+	after := r.NewGroup("after", 2<<10, getter)
+
+	if before.peerPicker() != picker || after.peerPicker() != picker {
+		t.Fatal("groups should use registry peers")
+	}
+
+	// Group 自己注册的节点优先，重复注册时 panic
+	own := &fakePicker{}
+	after.RegisterPeers(own)
+	if after.peerPicker() != own || before.peerPicker() != picker {
+		t.Fatal("group peers should override registry peers")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("registering group peers twice should panic")
+		}
+	}()
+	after.RegisterPeers(picker)
+}
+
+func TestRegistry_RegisterPeersConcurrent(t *testing.T) {
+	r := NewRegistry()
+	gc := r.NewGroup("peers-concurrent", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}))
+	// 处理请求的同时注册节点
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			gc.Get(strconv.Itoa(i))
+		}
+	}()
+	r.RegisterPeers(&fakePicker{})
+	<-done
+}
@@ -0,0 +1,381 @@
+package dcache
+
+import (
+	"dcache/cachepb"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// Setter 将数据写入后端存储
+type Setter interface {
+	Set(key string, value []byte) error
+}
+
+// SetterFunc 函数类型的 Setter
+type SetterFunc func(key string, value []byte) error
+
+func (f SetterFunc) Set(key string, value []byte) error {
+	return f(key, value)
+}
+
+// BatchSetter 可以一次写入多个key，write-behind 时优先使用
+type BatchSetter interface {
+	SetMany(values map[string][]byte) error
+}
+
+const (
+	writeRetries      = 3                      // write-behind 写入失败时的重试次数
+	writeRetryBackoff = 100 * time.Millisecond // 第一次重试前的等待时间，之后每次翻倍
+	writeMaxBackoff   = 30 * time.Second       // 重试失败的数据重新写入的最大等待时间
+)
+
+// Set 写入数据，tags 为记录的标签，可以通过 InvalidateTag 删除
+//   - 开启 write-through 时，先同步写入后端存储，成功后再更新缓存
+//   - 开启 write-behind 时，先更新缓存，再由后台批量写入后端存储
+//   - 都没有开启时只更新缓存
+//
+// 不属于本机的key 发送给所属的节点执行，由该节点写入后端存储并更新缓存，
+// 本机删除可能存在的旧值
+func (g *Group) Set(key string, value []byte, tags ...string) error {
+	if key == "" {
+		return errors.New("key is required ")
+	}
+	gen := g.Generation()
+	if peer, ok := g.ownerPeer(key, gen); ok {
+		err := g.putPeer(peer, &cachepb.PutRequest{
+			Group:      g.name,
+			Key:        key,
+			Value:      value,
+			Tags:       tags,
+			Generation: gen,
+		})
+		g.dropLocally(key)
+		return err
+	}
+	_, err := g.set(key, value, tags, nil)
+	return err
+}
+
+// ownerPeer 返回 key 所属的远程节点，属于本机时返回 false
+func (g *Group) ownerPeer(key string, gen uint64) (PeerGetter, bool) {
+	pickers := g.peerPicker()
+	if pickers == nil {
+		return nil, false
+	}
+	return pickers.PickPeer(genKey(key, gen))
+}
+
+// putPeer 将写入发送给 key 所属的节点
+func (g *Group) putPeer(peer PeerGetter, req *cachepb.PutRequest) error {
+	putter, ok := peer.(PeerPutter)
+	if !ok {
+		return errors.New("dcache: peer does not support set")
+	}
+	return putter.Put(req, &cachepb.PutResponse{})
+}
+
+// putResponse 处理远程节点发送的写入请求
+func (g *Group) putResponse(req *cachepb.PutRequest) (*cachepb.PutResponse, error) {
+	g.observeGeneration(req.GetGeneration())
+	if _, err := g.set(req.GetKey(), req.GetValue(), req.GetTags(), nil); err != nil {
+		return nil, err
+	}
+	return &cachepb.PutResponse{}, nil
+}
+
+// set 在 key 所属的节点上写入后端存储并更新缓存，返回放入缓存的记录，
+// 同一个key 的写入持有同一个写入锁，check 不为 nil 时先检查当前的记录，返回错误时不写入
+func (g *Group) set(key string, value []byte, tags []string, check func(cur entry, ok bool) error) (entry, error) {
+	mu := g.writeLock(key)
+	mu.Lock()
+	defer mu.Unlock()
+	if check != nil {
+		cur, ok := g.mainCache.getGen(key, g.Generation())
+		if err := check(cur, ok); err != nil {
+			return entry{}, err
+		}
+	}
+	if err := g.store(key, value, tags); err != nil {
+		return entry{}, err
+	}
+	return g.setLocally(key, value, tags), nil
+}
+
+// store 写入后端存储，都没有开启时什么也不做
+func (g *Group) store(key string, value []byte, tags []string) error {
+	switch {
+	case g.setter != nil:
+		return g.setter.Set(key, value)
+	case g.writer != nil:
+		g.writer.add(key, cloeBytes(value), tags)
+	}
+	return nil
+}
+
+// setLocally 用新的值更新本机的缓存，返回放入缓存的记录
+func (g *Group) setLocally(key string, value []byte, tags []string) entry {
+	g.dropLocally(key)
+	return g.populate(key, value, tags, 0, g.Generation(), 0)
+}
+
+// dropLocally 删除本机缓存的旧值，包括负缓存和磁盘缓存，正在加载的旧值不再放入缓存
+func (g *Group) dropLocally(key string) {
+	g.leases.void(key)
+	if g.negCache != nil {
+		g.negCache.remove(key)
+	}
+	g.deleteFromDisk(key)
+	g.mainCache.remove(key)
+}
+
+// Flush 等待 write-behind 队列中的数据全部写入后端存储，
+// 返回上次 Flush 之后写入失败的第一个错误，写入失败的数据留在队列中，之后重新写入
+func (g *Group) Flush() error {
+	if g.writer == nil {
+		return nil
+	}
+	return g.writer.flush()
+}
+
+// pendingWrite 等待写入的值，seq 用于判断写入期间是否被覆盖
+type pendingWrite struct {
+	value []byte
+	tags  []string
+	seq   uint64
+}
+
+// writeBehind 合并同一个key的多次写入，在后台批量写入后端存储
+type writeBehind struct {
+	setter    Setter
+	batchSize int
+	interval  time.Duration
+	backoff   time.Duration
+	stats     *Stats
+
+	mu      sync.Mutex
+	pending map[string]pendingWrite // 还没有写入成功的值，写入期间仍可以被读取
+	queue   []string                // 等待写入的key，按第一次写入的顺序
+	queued  map[string]bool         // 在 queue 中的key
+	seq     uint64
+	err     error // 上次 Flush 之后的第一个错误
+
+	kick    chan struct{}
+	flushCh chan chan error
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func newWriteBehind(setter Setter, batchSize int, interval time.Duration, stats *Stats) *writeBehind {
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	return &writeBehind{
+		setter:    setter,
+		batchSize: batchSize,
+		interval:  interval,
+		backoff:   writeRetryBackoff,
+		stats:     stats,
+		pending:   make(map[string]pendingWrite),
+		queued:    make(map[string]bool),
+		kick:      make(chan struct{}, 1),
+		flushCh:   make(chan chan error),
+	}
+}
+
+// add 加入队列，凑满一批时立即写入
+func (w *writeBehind) add(key string, value []byte, tags []string) {
+	w.mu.Lock()
+	// 正在写入的key 重新加入队列，写入新的值
+	if !w.queued[key] {
+		w.queued[key] = true
+		w.queue = append(w.queue, key)
+	}
+	w.seq++
+	w.pending[key] = pendingWrite{value: value, tags: tags, seq: w.seq}
+	full := len(w.queue) >= w.batchSize
+	w.mu.Unlock()
+	if full {
+		select {
+		case w.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// lookup 返回还没有写入成功的值
+func (w *writeBehind) lookup(key string) (pendingWrite, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	p, ok := w.pending[key]
+	return p, ok
+}
+
+func (w *writeBehind) start() {
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+	go w.run()
+}
+
+func (w *writeBehind) run() {
+	defer close(w.done)
+	var tick <-chan time.Time
+	if w.interval > 0 {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	// 写入失败时数据留在队列中，按指数退避重新写入，等待期间不因定时或凑满一批而写入
+	var retry <-chan time.Time
+	var delay time.Duration
+	write := func() {
+		if err := w.writeAll(); err == nil {
+			retry, delay = nil, 0
+			return
+		}
+		if delay *= 2; delay == 0 {
+			delay = w.backoff
+		}
+		if delay > writeMaxBackoff {
+			delay = writeMaxBackoff
+		}
+		retry = time.After(delay)
+	}
+	for {
+		select {
+		case <-tick:
+			if retry == nil {
+				write()
+			}
+		case <-w.kick:
+			if retry == nil {
+				write()
+			}
+		case <-retry:
+			write()
+		case reply := <-w.flushCh:
+			write()
+			reply <- w.takeErr()
+		case <-w.stop:
+			// 最后再写入一次，仍然失败时放弃，close 返回这次的错误
+			err := w.writeAll()
+			w.mu.Lock()
+			w.err = err
+			w.mu.Unlock()
+			return
+		}
+	}
+}
+
+// flush 让后台立即写入，并等待写入完成
+func (w *writeBehind) flush() error {
+	reply := make(chan error)
+	select {
+	case w.flushCh <- reply:
+		return <-reply
+	case <-w.done:
+		// 已经关闭，关闭时已经全部写入
+		return w.takeErr()
+	}
+}
+
+// close 写入剩余的数据并停止后台任务，返回最后一次写入失败的错误
+func (w *writeBehind) close() error {
+	close(w.stop)
+	<-w.done
+	return w.takeErr()
+}
+
+func (w *writeBehind) takeErr() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	err := w.err
+	w.err = nil
+	return err
+}
+
+// writeAll 按批写入队列中的所有数据，一批写入失败时重新放回队列头部并返回错误
+func (w *writeBehind) writeAll() error {
+	for {
+		w.mu.Lock()
+		n := len(w.queue)
+		if n == 0 {
+			w.mu.Unlock()
+			return nil
+		}
+		if n > w.batchSize {
+			n = w.batchSize
+		}
+		keys := w.queue[:n:n]
+		w.queue = w.queue[n:]
+		batch := make(map[string]pendingWrite, n)
+		for _, key := range keys {
+			delete(w.queued, key)
+			batch[key] = w.pending[key]
+		}
+		w.mu.Unlock()
+
+		err := w.writeBatch(batch)
+
+		w.mu.Lock()
+		if err != nil {
+			// 保留失败的值，写入期间被覆盖的key 已经重新加入队列
+			var failed []string
+			for _, key := range keys {
+				if !w.queued[key] {
+					w.queued[key] = true
+					failed = append(failed, key)
+				}
+			}
+			w.queue = append(failed, w.queue...)
+			if w.err == nil {
+				w.err = err
+			}
+			w.mu.Unlock()
+			return err
+		}
+		for key, p := range batch {
+			// 写入期间被覆盖的值已经重新加入队列，保留新的值
+			if cur, ok := w.pending[key]; ok && cur.seq == p.seq {
+				delete(w.pending, key)
+			}
+		}
+		w.mu.Unlock()
+	}
+}
+
+// writeBatch 写入一批数据，失败时按指数退避重试
+func (w *writeBehind) writeBatch(batch map[string]pendingWrite) error {
+	backoff := w.backoff
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = w.set(batch); err == nil {
+			return nil
+		}
+		if attempt == writeRetries {
+			break
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+	w.stats.WriteErrors.Add(int64(len(batch)))
+	log.Println("[cache] Failed to write behind", len(batch), "keys", err)
+	return err
+}
+
+func (w *writeBehind) set(batch map[string]pendingWrite) error {
+	if bs, ok := w.setter.(BatchSetter); ok {
+		values := make(map[string][]byte, len(batch))
+		for key, p := range batch {
+			values[key] = p.value
+		}
+		return bs.SetMany(values)
+	}
+	for key, p := range batch {
+		if err := w.setter.Set(key, p.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
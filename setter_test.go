@@ -0,0 +1,266 @@
+package dcache
+
+import (
+	"dcache/cachepb"
+	"errors"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeStore 记录写入的后端存储，fails 为接下来写入失败的次数
+type fakeStore struct {
+	mu      sync.Mutex
+	data    map[string]string
+	batches []map[string]string
+	fails   int
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{data: make(map[string]string)}
+}
+
+func (s *fakeStore) Get(key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if v, ok := s.data[key]; ok {
+		return []byte(v), nil
+	}
+	return nil, ErrNotFound
+}
+
+func (s *fakeStore) SetMany(values map[string][]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fails != 0 {
+		s.fails--
+		return errors.New("store unavailable")
+	}
+	batch := make(map[string]string, len(values))
+	for k, v := range values {
+		s.data[k] = string(v)
+		batch[k] = string(v)
+	}
+	s.batches = append(s.batches, batch)
+	return nil
+}
+
+func (s *fakeStore) Set(key string, value []byte) error {
+	return s.SetMany(map[string][]byte{key: value})
+}
+
+func TestGroup_WriteThrough(t *testing.T) {
+	store := newFakeStore()
+	store.data["k1"] = "old"
+	gc := NewRegistry().NewGroup("write-through", 2<<10, store,
+		WithWriteThrough(store), WithNegativeCache(time.Minute, 2<<10))
+
+	gc.Get("k1")
+	if _, err := gc.Get("k2"); !IsNotFound(err) {
+		t.Fatal("k2 should not exist")
+	}
+	if err := gc.Set("k1", []byte("new")); err != nil || store.data["k1"] != "new" {
+		t.Fatalf("set k1 err = %v, store = %v", err, store.data)
+	}
+	if v, _ := gc.Get("k1"); v.String() != "new" {
+		t.Fatalf("get k1 = %q", v.String())
+	}
+	// 写入后负缓存失效
+	gc.Set("k2", []byte("v2"))
+	if v, err := gc.Get("k2"); err != nil || v.String() != "v2" {
+		t.Fatalf("get k2 = %q, %v", v.String(), err)
+	}
+
+	// 写入失败时不更新缓存
+	store.fails = 1
+	if err := gc.Set("k1", []byte("failed")); err == nil {
+		t.Fatal("set should fail")
+	}
+	if v, _ := gc.Get("k1"); v.String() != "new" {
+		t.Fatalf("get k1 = %q after failed set", v.String())
+	}
+}
+
+func TestGroup_WriteBehind(t *testing.T) {
+	store := newFakeStore()
+	gc := NewRegistry().NewGroup("write-behind", 2*(5+entryOverhead), store,
+		WithWriteBehind(store, 2, 0))
+	gc.writer.backoff = time.Millisecond
+
+	gc.Set("k1", []byte("v1"))
+	gc.Set("k1", []byte("v1-2"))
+	gc.Set("k2", []byte("v2"))
+	gc.Set("k3", []byte("v3"))
+	// 合并同一个key 的写入，凑满一批时立即写入
+	if err := gc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	want := []map[string]string{{"k1": "v1-2", "k2": "v2"}, {"k3": "v3"}}
+	if !reflect.DeepEqual(store.batches, want) {
+		t.Fatalf("batches = %v", store.batches)
+	}
+
+	// 写入成功之前被淘汰的key，返回待写入的值
+	store.fails = 1
+	gc.Set("k4", []byte("v4"))
+	gc.Set("k5", []byte("v5"))
+	gc.Set("k6", []byte("v6"))
+	if v, err := gc.Get("k4"); err != nil || v.String() != "v4" {
+		t.Fatalf("get k4 = %q, %v", v.String(), err)
+	}
+	// 失败后重试
+	if err := gc.Flush(); err != nil || store.data["k4"] != "v4" || store.data["k6"] != "v6" {
+		t.Fatalf("flush err = %v, store = %v", err, store.data)
+	}
+
+	// 重试后仍然失败，数据留在队列中
+	store.fails = writeRetries + 1
+	gc.Set("k7", []byte("v7"))
+	if err := gc.Flush(); err == nil || gc.Stats.WriteErrors.Get() != 1 {
+		t.Fatalf("flush err = %v, write errors = %d", err, gc.Stats.WriteErrors.Get())
+	}
+	if p, ok := gc.writer.lookup("k7"); !ok || string(p.value) != "v7" {
+		t.Fatal("failed write should stay pending")
+	}
+	// 之后重新写入
+	if err := gc.Flush(); err != nil || store.data["k7"] != "v7" {
+		t.Fatalf("flush err = %v, store = %v", err, store.data)
+	}
+	if _, ok := gc.writer.lookup("k7"); ok {
+		t.Fatal("written value should not be pending")
+	}
+}
+
+func TestGroup_WriteBehindRetry(t *testing.T) {
+	r := NewRegistry()
+	store := newFakeStore()
+	gc := r.NewGroup("write-behind-retry", 2<<10, store, WithWriteBehind(store, 1, 0))
+	gc.writer.backoff = time.Millisecond
+
+	// 存储一直不可用时后台退避后重新写入，直到写入成功
+	store.mu.Lock()
+	store.fails = 3 * (writeRetries + 1)
+	store.mu.Unlock()
+	gc.Set("k1", []byte("v1"))
+	for i := 0; ; i++ {
+		store.mu.Lock()
+		v := store.data["k1"]
+		store.mu.Unlock()
+		if v == "v1" {
+			break
+		}
+		if i == 1000 {
+			t.Fatal("failed write should be retried")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// 关闭时最后写入仍然失败，返回错误
+	store.mu.Lock()
+	store.fails = 1000
+	store.mu.Unlock()
+	gc.Set("k2", []byte("v2"))
+	if err := gc.writer.close(); err == nil {
+		t.Fatal("close should report the failed write")
+	}
+}
+
+func TestGroup_WriteBehindClose(t *testing.T) {
+	r := NewRegistry()
+	store := newFakeStore()
+	gc := r.NewGroup("write-behind-close", 2<<10, store, WithWriteBehind(store, 100, time.Hour))
+	gc.Set("k1", []byte("v1"))
+	if len(store.batches) != 0 {
+		t.Fatal("write should be delayed")
+	}
+	// 删除 Group 时写入剩余的数据
+	r.DeleteGroup("write-behind-close")
+	if store.data["k1"] != "v1" {
+		t.Fatalf("store = %v", store.data)
+	}
+	if err := gc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// putPeer 记录收到的写入请求
+type putPeer struct {
+	fakePeer
+	reqs []*cachepb.PutRequest
+}
+
+func (p *putPeer) Put(in *cachepb.PutRequest, out *cachepb.PutResponse) error {
+	p.reqs = append(p.reqs, in)
+	return nil
+}
+
+func TestGroup_SetRemoteKey(t *testing.T) {
+	store := newFakeStore()
+	peer := &putPeer{}
+	gc := NewRegistry().NewGroup("set-remote", 2<<10, store, WithWriteThrough(store))
+	gc.RegisterPeers(&keyPicker{peer: peer})
+
+	gc.Set("local", []byte("v1"))
+	gc.populate("remote", []byte("old"), nil, 0, 0, 0)
+	gc.Set("remote", []byte("v2"), "t")
+	if _, ok := gc.mainCache.get("local"); !ok {
+		t.Fatal("local key should be cached")
+	}
+	// 不属于本机的key 发送给所属的节点写入，本机删除旧值
+	if _, ok := gc.mainCache.get("remote"); ok || store.data["remote"] != "" {
+		t.Fatal("remote key should be written by its owner")
+	}
+	if len(peer.reqs) != 1 || string(peer.reqs[0].Value) != "v2" || peer.reqs[0].Tags[0] != "t" {
+		t.Fatalf("unexpected requests: %v", peer.reqs)
+	}
+
+	gc = NewRegistry().NewGroup("set-remote-unsupported", 2<<10, store)
+	gc.RegisterPeers(&keyPicker{peer: &fakePeer{}})
+	if err := gc.Set("remote", []byte("v3")); err == nil {
+		t.Fatal("set should fail on unsupported peer")
+	}
+}
+
+func TestGroup_SetCluster(t *testing.T) {
+	// 所有节点共用同一个后端存储，write-behind 还没有写入时所属的节点也不会加载旧值
+	store := newFakeStore()
+	nodes := []*invalidateNode{
+		newClusterNode(store, WithWriteBehind(store, 100, 0)),
+		newClusterNode(store, WithWriteBehind(store, 100, 0)),
+	}
+	var addrs []string
+	for _, n := range nodes {
+		defer n.srv.Close()
+		addrs = append(addrs, n.srv.URL)
+	}
+	for _, n := range nodes {
+		n.pool.Set(HttpGetter, addrs...)
+	}
+	// 找到一个属于 nodes[1] 的key
+	var key string
+	for i := 0; key == ""; i++ {
+		k := "key" + strconv.Itoa(i)
+		if _, ok := nodes[0].pool.PickPeer(k); ok {
+			key = k
+		}
+	}
+	store.data[key] = "old"
+	if v, _ := nodes[1].group.Get(key); v.String() != "old" {
+		t.Fatalf("owner value = %q", v)
+	}
+
+	if err := nodes[0].group.Set(key, []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	for i, n := range nodes {
+		if v, err := n.group.Get(key); err != nil || v.String() != "new" {
+			t.Fatalf("node %d value = %q, %v", i, v, err)
+		}
+	}
+	nodes[1].group.Flush()
+	if store.data[key] != "new" {
+		t.Fatalf("store value = %q", store.data[key])
+	}
+}
@@ -39,6 +39,82 @@ func (g *Group) Do(key string, fn func() (interface{}, error)) (interface{}, err
 	g.m[key] = c  // 将这个请求保存
 	g.mu.Unlock() // 快速释放锁，减少Do 阻塞的时间
 
+	g.doCall(c, key, fn)
+	return c.val, c.err
+}
+
+// Go 没有相同key 的请求时在新的 goroutine 中执行 fn，返回是否执行
+// 已经有相同key 的请求时直接返回，不会创建 goroutine
+func (g *Group) Go(key string, fn func() (interface{}, error)) bool {
+	g.mu.Lock()
+	if g.m == nil {
+		g.m = make(map[string]*call)
+	}
+	if _, ok := g.m[key]; ok {
+		g.mu.Unlock()
+		return false
+	}
+	c := new(call)
+	c.wg.Add(1)
+	g.m[key] = c
+	g.mu.Unlock()
+
+	go g.doCall(c, key, fn)
+	return true
+}
+
+// Result DoMany 中每个key 的结果
+type Result struct {
+	Val interface{}
+	Err error
+}
+
+// DoMany 对多个key 只执行一次 fn，已经有相同key 的请求时等待该请求的结果，
+// fn 只处理没有相同请求的key，需要返回其中每个key 的结果
+func (g *Group) DoMany(keys []string, fn func(keys []string) map[string]Result) map[string]Result {
+	g.mu.Lock()
+	if g.m == nil {
+		g.m = make(map[string]*call)
+	}
+	calls := make(map[string]*call, len(keys))
+	var own []string
+	for _, key := range keys {
+		if c, ok := g.m[key]; ok {
+			calls[key] = c
+			continue
+		}
+		c := new(call)
+		c.wg.Add(1)
+		g.m[key] = c
+		calls[key] = c
+		own = append(own, key)
+	}
+	g.mu.Unlock()
+
+	if len(own) > 0 {
+		results := fn(own)
+		for _, key := range own {
+			c := calls[key]
+			c.val, c.err = results[key].Val, results[key].Err
+			c.wg.Done()
+		}
+		g.mu.Lock()
+		for _, key := range own {
+			delete(g.m, key)
+		}
+		g.mu.Unlock()
+	}
+
+	results := make(map[string]Result, len(keys))
+	for key, c := range calls {
+		c.wg.Wait()
+		results[key] = Result{Val: c.val, Err: c.err}
+	}
+	return results
+}
+
+// doCall 执行 fn，完成后唤醒等待的请求并释放标记
+func (g *Group) doCall(c *call, key string, fn func() (interface{}, error)) {
 	c.val, c.err = fn() // 调用回调函数
 	//time.Sleep(2 * time.Second) // 测试一个请求耗时非常长的想=情况
 	c.wg.Done() // 获取到调用结束后，立即释放
@@ -46,6 +122,4 @@ func (g *Group) Do(key string, fn func() (interface{}, error)) (interface{}, err
 	g.mu.Lock()
 	delete(g.m, key) // 调用结束，释放这个请求的标记
 	g.mu.Unlock()
-
-	return c.val, c.err
 }
@@ -0,0 +1,190 @@
+package dcache
+
+import (
+	"encoding/json"
+	"github.com/golang/protobuf/proto"
+)
+
+// Sink 接收 Group.GetInto 获取到的数据，将其转换为调用方需要的类型
+type Sink interface {
+	// SetString 设置字符串类型的值
+	SetString(s string) error
+	// SetBytes 设置字节类型的值，调用方可以在之后修改 v，因此需要拷贝
+	SetBytes(v []byte) error
+	// SetProto 设置 protobuf 消息
+	SetProto(m proto.Message) error
+
+	// setView 直接设置缓存中的值，避免多余的拷贝
+	setView(v ByteView) error
+}
+
+// GetInto 从缓存中获取数据并写入 dest，只拷贝一次
+func (g *Group) GetInto(key string, dest Sink) error {
+	view, err := g.Get(key)
+	if err != nil {
+		return err
+	}
+	return dest.setView(view)
+}
+
+// StringSink 将值写入 *string
+func StringSink(sp *string) Sink {
+	return &stringSink{sp: sp}
+}
+
+type stringSink struct {
+	sp *string
+}
+
+func (s *stringSink) SetString(v string) error {
+	*s.sp = v
+	return nil
+}
+
+func (s *stringSink) SetBytes(v []byte) error {
+	*s.sp = string(v)
+	return nil
+}
+
+func (s *stringSink) SetProto(m proto.Message) error {
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	*s.sp = string(b)
+	return nil
+}
+
+func (s *stringSink) setView(v ByteView) error {
+	*s.sp = v.String()
+	return nil
+}
+
+// ByteViewSink 将值写入 *ByteView，ByteView 只读，因此不需要拷贝
+func ByteViewSink(dst *ByteView) Sink {
+	if dst == nil {
+		panic("nil dst")
+	}
+	return &byteViewSink{dst: dst}
+}
+
+type byteViewSink struct {
+	dst *ByteView
+}
+
+func (s *byteViewSink) SetString(v string) error {
+	*s.dst = ByteView{b: []byte(v)}
+	return nil
+}
+
+func (s *byteViewSink) SetBytes(v []byte) error {
+	*s.dst = ByteView{b: cloeBytes(v)}
+	return nil
+}
+
+func (s *byteViewSink) SetProto(m proto.Message) error {
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	*s.dst = ByteView{b: b}
+	return nil
+}
+
+func (s *byteViewSink) setView(v ByteView) error {
+	*s.dst = v
+	return nil
+}
+
+// AllocatingByteSliceSink 将值拷贝到新分配的 []byte 中写入 *dst
+func AllocatingByteSliceSink(dst *[]byte) Sink {
+	return &allocBytesSink{dst: dst}
+}
+
+type allocBytesSink struct {
+	dst *[]byte
+}
+
+func (s *allocBytesSink) SetString(v string) error {
+	*s.dst = []byte(v)
+	return nil
+}
+
+func (s *allocBytesSink) SetBytes(v []byte) error {
+	*s.dst = cloeBytes(v)
+	return nil
+}
+
+func (s *allocBytesSink) SetProto(m proto.Message) error {
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	*s.dst = b
+	return nil
+}
+
+func (s *allocBytesSink) setView(v ByteView) error {
+	*s.dst = v.ByteSlice()
+	return nil
+}
+
+// ProtoSink 将值解码到 protobuf 消息 m 中
+func ProtoSink(m proto.Message) Sink {
+	return &protoSink{dst: m}
+}
+
+type protoSink struct {
+	dst proto.Message
+}
+
+func (s *protoSink) SetString(v string) error {
+	return s.SetBytes([]byte(v))
+}
+
+func (s *protoSink) SetBytes(v []byte) error {
+	return proto.Unmarshal(v, s.dst)
+}
+
+func (s *protoSink) SetProto(m proto.Message) error {
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(b, s.dst)
+}
+
+func (s *protoSink) setView(v ByteView) error {
+	// 解码时会拷贝数据，因此直接使用缓存中的值
+	return proto.Unmarshal(v.bytes(), s.dst)
+}
+
+// JSONSink 将 JSON 编码的值解码到 v 中，v 必须是指针
+func JSONSink(v interface{}) Sink {
+	return &jsonSink{dst: v}
+}
+
+type jsonSink struct {
+	dst interface{}
+}
+
+func (s *jsonSink) SetString(v string) error {
+	return s.SetBytes([]byte(v))
+}
+
+func (s *jsonSink) SetBytes(v []byte) error {
+	return json.Unmarshal(v, s.dst)
+}
+
+func (s *jsonSink) SetProto(m proto.Message) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, s.dst)
+}
+
+func (s *jsonSink) setView(v ByteView) error {
+	// 解码时会拷贝数据，因此直接使用缓存中的值
+	return json.Unmarshal(v.bytes(), s.dst)
+}
@@ -0,0 +1,80 @@
+package dcache
+
+import (
+	"dcache/cachepb"
+	"encoding/json"
+	"github.com/golang/protobuf/proto"
+	"testing"
+)
+
+type user struct {
+	Name  string `json:"name"`
+	Score int    `json:"score"`
+}
+
+func TestGroup_GetInto(t *testing.T) {
+	gc := NewGroup("sinks", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		switch key {
+		case "proto":
+			return proto.Marshal(&cachepb.Request{Group: "g", Key: "k"})
+		case "json":
+			return json.Marshal(user{Name: "Tom", Score: 630})
+		}
+		return []byte(key), nil
+	}))
+
+	var s string
+	if err := gc.GetInto("hello", StringSink(&s)); err != nil || s != "hello" {
+		t.Fatalf("StringSink got %q, %v", s, err)
+	}
+
+	var view ByteView
+	if err := gc.GetInto("hello", ByteViewSink(&view)); err != nil || view.String() != "hello" {
+		t.Fatalf("ByteViewSink got %q, %v", view, err)
+	}
+
+	var b []byte
+	if err := gc.GetInto("hello", AllocatingByteSliceSink(&b)); err != nil || string(b) != "hello" {
+		t.Fatalf("AllocatingByteSliceSink got %q, %v", b, err)
+	}
+	// 修改返回的切片不能影响缓存中的值
+	b[0] = 'H'
+	if v, _ := gc.Get("hello"); v.String() != "hello" {
+		t.Fatal("cached value was modified")
+	}
+
+	req := &cachepb.Request{}
+	if err := gc.GetInto("proto", ProtoSink(req)); err != nil || req.Group != "g" || req.Key != "k" {
+		t.Fatalf("ProtoSink got %v, %v", req, err)
+	}
+
+	var u user
+	if err := gc.GetInto("json", JSONSink(&u)); err != nil || u != (user{Name: "Tom", Score: 630}) {
+		t.Fatalf("JSONSink got %v, %v", u, err)
+	}
+}
+
+func TestSink_Set(t *testing.T) {
+	var s string
+	if err := StringSink(&s).SetBytes([]byte("abc")); err != nil || s != "abc" {
+		t.Fatal("StringSink.SetBytes failed")
+	}
+
+	src := []byte("abc")
+	var view ByteView
+	ByteViewSink(&view).SetBytes(src)
+	src[0] = 'x'
+	if view.String() != "abc" {
+		t.Fatal("ByteViewSink.SetBytes should copy")
+	}
+
+	req := &cachepb.Request{}
+	if err := ProtoSink(req).SetProto(&cachepb.Request{Key: "k"}); err != nil || req.Key != "k" {
+		t.Fatal("ProtoSink.SetProto failed")
+	}
+
+	var u user
+	if err := JSONSink(&u).SetString(`{"name":"Sam","score":567}`); err != nil || u.Name != "Sam" {
+		t.Fatal("JSONSink.SetString failed")
+	}
+}
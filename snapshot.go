@@ -0,0 +1,441 @@
+package dcache
+
+import (
+	"bufio"
+	"bytes"
+	"dcache/cachepb"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+/*
+快照文件格式，由若干 record 组成，整数均为小端序：
+
+	record:  uvarint len(payload) | payload | crc32c(payload) uint32
+	header:  magic "DCSN" | version uint16 | uvarint len(name) | name | uvarint generation（版本 3）
+	entry:   uvarint len(key) | key | uvarint compression | checksum uint32 | varint cost |
+	         varint fresh | varint expire | uvarint len(value) | value |
+	         uvarint len(tags) | { uvarint len(tag) | tag }（版本 2）| uvarint generation（版本 3）
+	trailer: uvarint 0 | uvarint 记录条数
+
+第一条 record 为 header，之后每条 record 对应一条缓存记录，按访问顺序从旧到新排列，
+恢复时依次添加即可还原访问顺序。时间使用 UnixNano，0 表示零值。
+value 为缓存中存储的形式（可能已经压缩），恢复时用记录的校验和校验解压后的值。
+*/
+
+const (
+	snapshotMagic   = "DCSN"
+	snapshotVersion = 3 // 版本 2 增加了记录的标签，版本 3 增加了代
+
+	maxRecordSize = math.MaxInt32 - 4 // record 中 payload 的最大长度，超过时视为损坏
+)
+
+// ErrBadSnapshot 快照文件损坏、被截断或者格式不支持
+var ErrBadSnapshot = errors.New("dcache: bad snapshot")
+
+// Snapshot 将缓存中未过期的记录写入 w
+// 写入时不持有缓存的锁，写入过程中缓存的变化不一定包含在快照中
+func (g *Group) Snapshot(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	var header snapshotBuffer
+	header = append(header, snapshotMagic...)
+	header.uint16(snapshotVersion)
+	header.bytes([]byte(g.name))
+	header.uvarint(g.Generation())
+	if err := writeRecord(bw, header); err != nil {
+		return err
+	}
+
+	entries := g.mainCache.entries()
+	for _, ce := range entries {
+		if err := writeRecord(bw, encodeEntry(ce)); err != nil {
+			return err
+		}
+	}
+
+	var trailer snapshotBuffer
+	trailer.uvarint(0)
+	trailer.uvarint(uint64(len(entries)))
+	if _, err := bw.Write(trailer); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// Restore 从 r 中读取快照并添加到缓存，返回添加的记录数
+// 快照损坏或被截断时返回 ErrBadSnapshot，不添加任何记录；
+// 已经过期以及校验和不一致的记录会被跳过
+func (g *Group) Restore(r io.Reader) (int, error) {
+	br := bufio.NewReader(r)
+
+	payload, err := readRecord(br)
+	if err != nil {
+		return 0, err
+	}
+	header := snapshotReader{b: payload}
+	magic := header.next(len(snapshotMagic))
+	version := header.uint16()
+	name := header.bytes()
+	var gen uint64
+	if version >= 3 {
+		gen = header.uvarint()
+	}
+	switch {
+	case header.err != nil || len(header.b) != 0 || string(magic) != snapshotMagic:
+		return 0, fmt.Errorf("%w: bad header", ErrBadSnapshot)
+	case version == 0 || version > snapshotVersion:
+		return 0, fmt.Errorf("%w: unsupported version %d", ErrBadSnapshot, version)
+	case string(name) != g.name:
+		return 0, fmt.Errorf("%w: snapshot of group %q", ErrBadSnapshot, name)
+	}
+
+	// 先读取全部记录，确认快照完整之后再添加
+	var entries []cachedEntry
+	for {
+		payload, err := readRecord(br)
+		if err != nil {
+			return 0, err
+		}
+		if payload == nil {
+			break
+		}
+		ce, err := g.decodeEntry(payload, version)
+		if err != nil {
+			return 0, err
+		}
+		entries = append(entries, ce)
+	}
+	count, err := binary.ReadUvarint(br)
+	if err != nil || count != uint64(len(entries)) {
+		return 0, fmt.Errorf("%w: bad trailer", ErrBadSnapshot)
+	}
+
+	g.observeGeneration(gen)
+	gen = g.Generation()
+	now := g.now()
+	n := 0
+	for _, ce := range entries {
+		if ce.entry.gen != gen || !ce.expire.IsZero() && !now.Before(ce.expire) {
+			continue
+		}
+		if err := g.verify(ce.entry); err != nil {
+			log.Println("[cache] Skip snapshot entry", ce.key, err)
+			continue
+		}
+		g.mainCache.addWithExpire(ce.key, ce.entry, ce.expire)
+		g.tags.set(ce.key, ce.entry.tags)
+		n++
+	}
+	if g.registry != nil {
+		g.registry.enforceBudget()
+	}
+	return n, nil
+}
+
+// encodeEntry 编码一条缓存记录，快照和磁盘缓存使用相同的格式
+func encodeEntry(ce cachedEntry) []byte {
+	var b snapshotBuffer
+	b.bytes([]byte(ce.key))
+	b.uvarint(uint64(ce.entry.compression))
+	b.uint32(ce.entry.checksum)
+	b.varint(int64(ce.entry.cost))
+	b.time(ce.entry.fresh)
+	b.time(ce.expire)
+	b.uvarint(uint64(ce.entry.value.Len()))
+	ce.entry.value.eachChunk(func(c []byte) bool {
+		b = append(b, c...)
+		return true
+	})
+	b.uvarint(uint64(len(ce.entry.tags)))
+	for _, tag := range ce.entry.tags {
+		b.bytes([]byte(tag))
+	}
+	b.uvarint(ce.entry.gen)
+	return b
+}
+
+// decodeEntry 解析 version 版本的一条缓存记录，大的值按 Group 的配置分块存储
+func (g *Group) decodeEntry(payload []byte, version uint16) (cachedEntry, error) {
+	r := snapshotReader{b: payload}
+	key := r.bytes()
+	compression := r.uvarint()
+	sum := r.uint32()
+	cost := r.varint()
+	fresh := r.time()
+	expire := r.time()
+	data := r.bytes()
+	var tags []string
+	if version >= 2 {
+		n := r.uvarint()
+		if n > uint64(len(r.b)) {
+			r.err = ErrBadSnapshot
+		}
+		for i := uint64(0); i < n && r.err == nil; i++ {
+			tags = append(tags, string(r.bytes()))
+		}
+	}
+	var gen uint64
+	if version >= 3 {
+		gen = r.uvarint()
+	}
+	if r.err != nil || len(r.b) != 0 {
+		return cachedEntry{}, fmt.Errorf("%w: bad entry", ErrBadSnapshot)
+	}
+	if _, ok := cachepb.Compression_name[int32(compression)]; !ok {
+		return cachedEntry{}, fmt.Errorf("%w: unknown compression %d", ErrBadSnapshot, compression)
+	}
+
+	var value ByteView
+	if g.chunkSize > 0 && len(data) > g.chunkThreshold {
+		value = chunkedView(splitChunks(data, g.chunkSize))
+	} else {
+		// 拷贝一份，避免引用整个 payload
+		value = ByteView{b: cloeBytes(data)}
+	}
+	return cachedEntry{
+		key: string(key),
+		entry: entry{
+			value:       value,
+			compression: cachepb.Compression(compression),
+			checksum:    sum,
+			cost:        time.Duration(cost),
+			fresh:       fresh,
+			tags:        tags,
+			gen:         gen,
+			version:     g.nextVersion(),
+		},
+		expire: expire,
+	}, nil
+}
+
+// SaveSnapshot 将快照写入文件
+// 先写入同一目录下的临时文件再重命名，写入失败时不会破坏已有的快照
+func (g *Group) SaveSnapshot(path string) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	err = g.Snapshot(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// LoadSnapshot 从文件中恢复缓存，返回添加的记录数
+func (g *Group) LoadSnapshot(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return g.Restore(f)
+}
+
+// startSnapshots 从快照文件恢复缓存，并定期保存快照
+func (g *Group) startSnapshots() {
+	if n, err := g.LoadSnapshot(g.snapshotPath); err == nil {
+		log.Printf("[cache] Restored %d entries of group %s from %s", n, g.name, g.snapshotPath)
+	} else if !os.IsNotExist(err) {
+		log.Println("[cache] Failed to load snapshot", err)
+	}
+	if g.snapshotInterval <= 0 {
+		return
+	}
+	g.snapshotStop = make(chan struct{})
+	g.snapshotDone = make(chan struct{})
+	go func() {
+		defer close(g.snapshotDone)
+		ticker := time.NewTicker(g.snapshotInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := g.SaveSnapshot(g.snapshotPath); err != nil {
+					log.Println("[cache] Failed to save snapshot", err)
+				}
+			case <-g.snapshotStop:
+				return
+			}
+		}
+	}()
+}
+
+// stopSnapshots 停止定期保存，并保存最后一次快照
+func (g *Group) stopSnapshots() {
+	if g.snapshotStop != nil {
+		close(g.snapshotStop)
+		<-g.snapshotDone
+		g.snapshotStop = nil
+	}
+	if err := g.SaveSnapshot(g.snapshotPath); err != nil {
+		log.Println("[cache] Failed to save snapshot", err)
+	}
+}
+
+// writeRecord 写入一条 record
+func writeRecord(w io.Writer, payload []byte) error {
+	var b snapshotBuffer
+	b.uvarint(uint64(len(payload)))
+	b = append(b, payload...)
+	b.uint32(crc32.Checksum(payload, crc32c))
+	_, err := w.Write(b)
+	return err
+}
+
+// readRecord 读取一条 record 并校验，读到 trailer 时返回 nil
+func readRecord(r *bufio.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadSnapshot, err)
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	if n > maxRecordSize {
+		return nil, fmt.Errorf("%w: record too large", ErrBadSnapshot)
+	}
+	// 按实际读到的数据分配内存，长度损坏时不会一次分配过多
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, r, int64(n)+4); err != nil {
+		return nil, fmt.Errorf("%w: truncated record", ErrBadSnapshot)
+	}
+	b := buf.Bytes()
+	if uint64(len(b)) != n+4 {
+		return nil, fmt.Errorf("%w: truncated record", ErrBadSnapshot)
+	}
+	payload, sum := b[:n], binary.LittleEndian.Uint32(b[n:])
+	if crc32.Checksum(payload, crc32c) != sum {
+		return nil, fmt.Errorf("%w: record checksum mismatch", ErrBadSnapshot)
+	}
+	return payload, nil
+}
+
+// snapshotBuffer 编码快照
+type snapshotBuffer []byte
+
+func (b *snapshotBuffer) uvarint(v uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	*b = append(*b, tmp[:binary.PutUvarint(tmp[:], v)]...)
+}
+
+func (b *snapshotBuffer) varint(v int64) {
+	var tmp [binary.MaxVarintLen64]byte
+	*b = append(*b, tmp[:binary.PutVarint(tmp[:], v)]...)
+}
+
+func (b *snapshotBuffer) uint16(v uint16) {
+	var tmp [2]byte
+	binary.LittleEndian.PutUint16(tmp[:], v)
+	*b = append(*b, tmp[:]...)
+}
+
+func (b *snapshotBuffer) uint32(v uint32) {
+	var tmp [4]byte
+	binary.LittleEndian.PutUint32(tmp[:], v)
+	*b = append(*b, tmp[:]...)
+}
+
+func (b *snapshotBuffer) bytes(p []byte) {
+	b.uvarint(uint64(len(p)))
+	*b = append(*b, p...)
+}
+
+func (b *snapshotBuffer) time(t time.Time) {
+	if t.IsZero() {
+		b.varint(0)
+		return
+	}
+	b.varint(t.UnixNano())
+}
+
+// snapshotReader 解码快照，出错后的读取都返回零值，最后检查 err 即可
+type snapshotReader struct {
+	b   []byte
+	err error
+}
+
+func (r *snapshotReader) next(n int) []byte {
+	if r.err != nil || n < 0 || n > len(r.b) {
+		r.err = ErrBadSnapshot
+		return nil
+	}
+	p := r.b[:n]
+	r.b = r.b[n:]
+	return p
+}
+
+func (r *snapshotReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.b)
+	if n <= 0 {
+		r.err = ErrBadSnapshot
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *snapshotReader) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(r.b)
+	if n <= 0 {
+		r.err = ErrBadSnapshot
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *snapshotReader) uint16() uint16 {
+	if p := r.next(2); p != nil {
+		return binary.LittleEndian.Uint16(p)
+	}
+	return 0
+}
+
+func (r *snapshotReader) uint32() uint32 {
+	if p := r.next(4); p != nil {
+		return binary.LittleEndian.Uint32(p)
+	}
+	return 0
+}
+
+func (r *snapshotReader) bytes() []byte {
+	n := r.uvarint()
+	if n > uint64(len(r.b)) {
+		r.err = ErrBadSnapshot
+		return nil
+	}
+	return r.next(int(n))
+}
+
+func (r *snapshotReader) time() time.Time {
+	if n := r.varint(); n != 0 {
+		return time.Unix(0, n)
+	}
+	return time.Time{}
+}
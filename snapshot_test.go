@@ -0,0 +1,155 @@
+package dcache
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// snapshotGroup 创建一个带 TTL 和压缩的 Group，loads 记录加载次数
+func snapshotGroup(r *Registry, clock *fakeClock, loads *int) *Group {
+	return r.NewGroup("snapshot", 1<<20, GetterFunc(func(key string) ([]byte, error) {
+		*loads++
+		return []byte(strings.Repeat(key, 100)), nil
+	}), withClock(clock.Now), WithTTL(time.Minute), WithCompression(Gzip, 64), WithChunking(128, 64))
+}
+
+func TestGroup_SnapshotRestore(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	var loads int
+	src := snapshotGroup(NewRegistry(), clock, &loads)
+	short := NewRegistry().NewGroup("snapshot", 1<<20, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}), withClock(clock.Now), WithTTL(time.Second))
+	for _, k := range []string{"a", "bb", "c"} {
+		src.Get(k)
+	}
+	src.Get("a")
+	short.Get("expired")
+
+	var buf bytes.Buffer
+	if err := src.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(30 * time.Second)
+
+	dst := snapshotGroup(NewRegistry(), clock, &loads)
+	if n, err := dst.Restore(bytes.NewReader(buf.Bytes())); err != nil || n != 3 {
+		t.Fatalf("restore n = %d, err = %v", n, err)
+	}
+	// 恢复访问顺序和过期时间
+	if keys := dst.mainCache.lru.Keys(); !reflect.DeepEqual(keys, []string{"a", "c", "bb"}) {
+		t.Fatalf("keys = %v", keys)
+	}
+	loads = 0
+	for _, k := range []string{"a", "bb", "c"} {
+		if v, err := dst.Get(k); err != nil || v.String() != strings.Repeat(k, 100) {
+			t.Fatalf("get %s = %q, %v", k, v.String(), err)
+		}
+	}
+	if loads != 0 {
+		t.Fatalf("restored keys should not be loaded, loads = %d", loads)
+	}
+	if e, _ := dst.mainCache.get("bb"); !e.fresh.Equal(time.Unix(1060, 0)) {
+		t.Fatalf("fresh = %v", e.fresh)
+	}
+
+	// 过期的记录不恢复
+	buf.Reset()
+	if err := short.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := short.Restore(&buf); err != nil || n != 0 {
+		t.Fatalf("expired entries restored, n = %d, err = %v", n, err)
+	}
+
+	// 不同名字的 Group 不能恢复
+	buf.Reset()
+	src.Snapshot(&buf)
+	other := NewRegistry().NewGroup("other", 1<<20, GetterFunc(func(key string) ([]byte, error) {
+		return nil, ErrNotFound
+	}))
+	if _, err := other.Restore(&buf); !errors.Is(err, ErrBadSnapshot) {
+		t.Fatalf("err = %v, want ErrBadSnapshot", err)
+	}
+}
+
+func TestGroup_RestoreCorrupted(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	var loads int
+	src := snapshotGroup(NewRegistry(), clock, &loads)
+	for _, k := range []string{"a", "bb", "c"} {
+		src.Get(k)
+	}
+	var buf bytes.Buffer
+	if err := src.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	check := func(name string, b []byte) {
+		t.Helper()
+		dst := snapshotGroup(NewRegistry(), clock, &loads)
+		n, err := dst.Restore(bytes.NewReader(b))
+		if !errors.Is(err, ErrBadSnapshot) || n != 0 || dst.mainCache.bytes() != 0 {
+			t.Fatalf("%s: n = %d, err = %v", name, n, err)
+		}
+	}
+	// 任意位置截断
+	for i := 0; i < len(data); i++ {
+		check("truncated", data[:i])
+	}
+	// 任意位置的数据损坏
+	for i := 0; i < len(data); i++ {
+		b := append([]byte(nil), data...)
+		b[i] ^= 0x40
+		check("corrupted", b)
+	}
+	// 损坏的 record 长度
+	check("bad length", []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01, 'a', 'b', 'c'})
+	check("bad length", append([]byte{0xfc, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f}, data...))
+}
+
+func TestGroup_SnapshotFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot")
+	r := NewRegistry()
+	getter := GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	})
+	if _, err := r.NewGroup("file", 1<<20, getter).LoadSnapshot(path); err == nil {
+		t.Fatal("load missing snapshot should fail")
+	}
+
+	// 删除 Group 时保存快照，重新创建时恢复
+	g := r.NewGroup("file", 1<<20, getter, WithSnapshot(path, time.Hour))
+	g.Get("k1")
+	g.Get("k2")
+	r.DeleteGroup("file")
+
+	g = r.NewGroup("file", 1<<20, getter, WithSnapshot(path, time.Hour))
+	defer r.DeleteGroup("file")
+	if keys := g.mainCache.lru.Keys(); !reflect.DeepEqual(keys, []string{"k2", "k1"}) {
+		t.Fatalf("keys = %v", keys)
+	}
+
+	// 没有被接受的 Group 不会启动定期快照
+	g.Get("k3")
+	if _, err := r.CreateGroup("file", 1<<20, getter, WithSnapshot(path, time.Millisecond)); err != ErrGroupExists {
+		t.Fatalf("err = %v, want ErrGroupExists", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	// 被覆盖的 Group 保存快照，新的 Group 从快照恢复
+	g = r.NewGroup("file", 1<<20, getter, WithSnapshot(path, time.Hour))
+	time.Sleep(20 * time.Millisecond)
+	if n, err := g.LoadSnapshot(path); err != nil || n != 3 {
+		t.Fatalf("restored %d entries, err = %v", n, err)
+	}
+	matches, _ := filepath.Glob(path + ".tmp*")
+	if len(matches) != 0 {
+		t.Fatalf("temp files left: %v", matches)
+	}
+}
@@ -0,0 +1,32 @@
+package dcache
+
+import (
+	"strconv"
+	"sync/atomic"
+)
+
+// AtomicInt 可以并发使用的计数器
+type AtomicInt int64
+
+// Add 增加 n
+func (i *AtomicInt) Add(n int64) {
+	atomic.AddInt64((*int64)(i), n)
+}
+
+// Get 获取当前值
+func (i *AtomicInt) Get() int64 {
+	return atomic.LoadInt64((*int64)(i))
+}
+
+func (i *AtomicInt) String() string {
+	return strconv.FormatInt(i.Get(), 10)
+}
+
+// Stats Group 的统计信息
+type Stats struct {
+	CacheHits          AtomicInt // 本地缓存命中的次数
+	ChecksumMismatches AtomicInt // 远程节点返回的值校验和不一致的次数
+	DiskHits           AtomicInt // 磁盘缓存命中的次数
+	WriteErrors        AtomicInt // write-behind 重试后仍写入失败的key 的个数
+	StaleSets          AtomicInt // 租约作废后没有放入缓存的加载结果的个数
+}
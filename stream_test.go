@@ -0,0 +1,133 @@
+package dcache
+
+import (
+	"bytes"
+	"dcache/cachepb"
+	"encoding/binary"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var largeValue = bytes.Repeat([]byte("0123456789"), streamChunkSize/4)
+
+func newLargeGroup(name string) *Group {
+	return NewGroup(name, 1<<20, GetterFunc(func(key string) ([]byte, error) {
+		if key == "large" {
+			return largeValue, nil
+		}
+		return nil, ErrNotFound
+	}), WithChunking(1024, 4096))
+}
+
+func TestGroup_Chunking(t *testing.T) {
+	gc := newLargeGroup("chunking")
+	v, err := gc.Get("large")
+	if err != nil || !v.EqualBytes(largeValue) {
+		t.Fatal("get large value failed")
+	}
+	e, _ := gc.mainCache.get("large")
+	if len(e.value.chunks) != (len(largeValue)+4095)/4096 {
+		t.Fatalf("stored in %d chunks", len(e.value.chunks))
+	}
+}
+
+func TestHTTPPool_GetStream(t *testing.T) {
+	newLargeGroup("stream-http")
+	srv := httptest.NewServer(NewHTTPPool("self"))
+	defer srv.Close()
+
+	getter := &httpGetter{baseURL: srv.URL + defaultBasePath}
+	header, chunks, err := getter.GetStream(&cachepb.Request{Group: "stream-http", Key: "large"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.NotFound || len(chunks) < 2 || !chunkedView(chunks).EqualBytes(largeValue) {
+		t.Fatalf("unexpected stream: %d chunks", len(chunks))
+	}
+
+	header, _, err = getter.GetStream(&cachepb.Request{Group: "stream-http", Key: "missing"})
+	if err != nil || !header.NotFound {
+		t.Fatalf("missing key should be not found: %v", err)
+	}
+}
+
+func TestRPC_GetStream(t *testing.T) {
+	newLargeGroup("stream-rpc")
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	cachepb.RegisterGroupCacheServer(s, NewHTTPPool("self"))
+	go s.Serve(l)
+	defer s.Stop()
+
+	getter := &rpcGetter{baseRPCAddr: l.Addr().String()}
+	header, chunks, err := getter.GetStream(&cachepb.Request{Group: "stream-rpc", Key: "large"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.NotFound || len(chunks) < 2 || !chunkedView(chunks).EqualBytes(largeValue) {
+		t.Fatalf("unexpected stream: %d chunks", len(chunks))
+	}
+
+	// 通过 getFromPeer 获取，结果分块保存
+	e, err := GetGroup("stream-rpc").getFromPeer(getter, "large")
+	if err != nil || e.value.chunks == nil || !e.value.EqualBytes(largeValue) {
+		t.Fatalf("getFromPeer failed: %v", err)
+	}
+}
+
+func TestGroup_GetFromPeerStreamThreshold(t *testing.T) {
+	gc := NewGroup("stream-threshold", 1<<20, GetterFunc(func(key string) ([]byte, error) {
+		if key == "large" {
+			return largeValue, nil
+		}
+		return []byte(key), nil
+	}), WithChunking(1024, 4096))
+	pool := NewHTTPPool("self")
+	streams := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(streamHeader) != "" {
+			streams++
+		}
+		pool.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	getter := &httpGetter{baseURL: srv.URL + defaultBasePath}
+
+	// 小的值不分块传输
+	e, err := gc.getFromPeer(getter, "small")
+	if err != nil || e.value.String() != "small" || streams != 0 {
+		t.Fatalf("small value: %v, streams = %d", err, streams)
+	}
+	e, err = gc.getFromPeer(getter, "large")
+	if err != nil || !e.value.EqualBytes(largeValue) || streams != 1 {
+		t.Fatalf("large value: %v, streams = %d", err, streams)
+	}
+	// 缓冲区大小与实际读取的数据一致
+	for _, c := range e.value.chunks {
+		if cap(c) != len(c) {
+			t.Fatalf("chunk len = %d, cap = %d", len(c), cap(c))
+		}
+	}
+}
+
+func TestHTTPGetter_GetStreamTruncated(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hb, _ := proto.Marshal(&cachepb.Response{Size: 10})
+		prefix := make([]byte, binary.MaxVarintLen64)
+		n := binary.PutUvarint(prefix, uint64(len(hb)))
+		w.Write(append(append(prefix[:n], hb...), "short"...))
+	}))
+	defer srv.Close()
+
+	getter := &httpGetter{baseURL: srv.URL + defaultBasePath}
+	if _, _, err := getter.GetStream(&cachepb.Request{Group: "g", Key: "k"}); err == nil {
+		t.Fatal("truncated body should be rejected")
+	}
+}
@@ -0,0 +1,121 @@
+package dcache
+
+import (
+	"dcache/cachepb"
+	"strings"
+	"sync"
+)
+
+// TagGetter 获取源数据的同时返回记录的标签，
+// 同一个上游实体派生出的key 使用相同的标签，之后可以通过 InvalidateTag 一起删除
+type TagGetter interface {
+	Getter
+	GetWithTags(key string) (value []byte, tags []string, err error)
+}
+
+// TagGetterFunc 同时实现了 Getter 和 TagGetter
+type TagGetterFunc func(key string) ([]byte, []string, error)
+
+func (f TagGetterFunc) Get(key string) ([]byte, error) {
+	b, _, err := f(key)
+	return b, err
+}
+
+func (f TagGetterFunc) GetWithTags(key string) ([]byte, []string, error) {
+	return f(key)
+}
+
+// BatchTagGetter 批量获取源数据的同时返回记录的标签，
+// Getter 实现了 TagGetter 但没有实现 BatchTagGetter 时，GetMany 逐个调用 GetWithTags
+type BatchTagGetter interface {
+	GetManyWithTags(keys []string) (values map[string][]byte, tags map[string][]string, err error)
+}
+
+// InvalidateTag 删除本机以及所有远程节点上带有 tag 标签的缓存
+func (g *Group) InvalidateTag(tag string) error {
+	return g.broadcast(&cachepb.InvalidateRequest{Group: g.name, Tag: tag})
+}
+
+// invalidateTag 删除本机带有 tag 标签的缓存，包括磁盘缓存，返回删除的条数
+func (g *Group) invalidateTag(tag string) int64 {
+	// 正在加载的key 的标签还不知道，加载完成时再检查标签
+	g.leases.voidTag(tag)
+	var n int64
+	for _, key := range g.tags.keysOf(tag) {
+		if g.mainCache.remove(key) {
+			n++
+		}
+		g.deleteFromDisk(key)
+		g.tags.remove(key)
+	}
+	return n
+}
+
+// tagIndex 标签到key 的索引，包括本地缓存以及磁盘缓存中的记录，零值可以直接使用
+type tagIndex struct {
+	mu   sync.Mutex
+	tags map[string]map[string]struct{} // 标签对应的key
+	keys map[string][]string            // key 对应的标签
+}
+
+// set 设置 key 的标签，替换原来的标签
+func (t *tagIndex) set(key string, tags []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.removeLocked(key)
+	if len(tags) == 0 {
+		return
+	}
+	if t.tags == nil {
+		t.tags = make(map[string]map[string]struct{})
+		t.keys = make(map[string][]string)
+	}
+	t.keys[key] = tags
+	for _, tag := range tags {
+		keys, ok := t.tags[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			t.tags[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+}
+
+// remove 删除 key 的标签
+func (t *tagIndex) remove(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.removeLocked(key)
+}
+
+func (t *tagIndex) removeLocked(key string) {
+	for _, tag := range t.keys[key] {
+		delete(t.tags[tag], key)
+		if len(t.tags[tag]) == 0 {
+			delete(t.tags, tag)
+		}
+	}
+	delete(t.keys, key)
+}
+
+// removePrefix 删除所有以 prefix 为前缀的key 的标签
+func (t *tagIndex) removePrefix(prefix string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for key := range t.keys {
+		if strings.HasPrefix(key, prefix) {
+			t.removeLocked(key)
+		}
+	}
+}
+
+// keysOf 返回带有 tag 标签的所有key
+func (t *tagIndex) keysOf(tag string) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	keys := make([]string, 0, len(t.tags[tag]))
+	for key := range t.tags[tag] {
+		keys = append(keys, key)
+	}
+	return keys
+}
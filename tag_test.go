@@ -0,0 +1,186 @@
+package dcache
+
+import (
+	"bytes"
+	"dcache/disk"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// tagGetter 值为 key，标签为 key 中冒号之前的部分
+var tagGetter = TagGetterFunc(func(key string) ([]byte, []string, error) {
+	return []byte(key), []string{strings.SplitN(key, ":", 2)[0]}, nil
+})
+
+func TestGroup_InvalidateTag(t *testing.T) {
+	gc := NewRegistry().NewGroup("tags", 2<<10, tagGetter)
+	for _, k := range []string{"user:1", "user:2", "order:1"} {
+		gc.Get(k)
+	}
+	gc.Set("profile", []byte("p"), "user", "profile")
+
+	keys := gc.tags.keysOf("user")
+	sort.Strings(keys)
+	if strings.Join(keys, ",") != "profile,user:1,user:2" {
+		t.Fatalf("keys of tag user = %v", keys)
+	}
+	if err := gc.InvalidateTag("user"); err != nil {
+		t.Fatal(err)
+	}
+	if keys := gc.mainCache.lru.Keys(); len(keys) != 1 || keys[0] != "order:1" {
+		t.Fatalf("keys = %v", keys)
+	}
+	// 删除后索引中不再有这些key
+	if len(gc.tags.keys) != 1 || len(gc.tags.keysOf("profile")) != 0 {
+		t.Fatalf("tag index not cleaned: %v", gc.tags.keys)
+	}
+
+	// 重新写入时替换原来的标签
+	gc.Set("order:1", []byte("o"), "archived")
+	if len(gc.tags.keysOf("order")) != 0 || len(gc.tags.keysOf("archived")) != 1 {
+		t.Fatal("tags should be replaced")
+	}
+	// 因容量被淘汰时删除索引
+	gc.SetCacheBytes(1)
+	if len(gc.tags.keys) != 0 {
+		t.Fatalf("tag index not cleaned after eviction: %v", gc.tags.keys)
+	}
+}
+
+func TestGroup_InvalidateTagDiskAndPeers(t *testing.T) {
+	store, err := disk.Open(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	peer := &flakyInvalidator{}
+	gc := NewRegistry().NewGroup("tags-disk", 2*(12+entryOverhead), tagGetter, WithDiskTier(store))
+	gc.RegisterPeers(&listPicker{peers: []PeerGetter{peer}})
+
+	for _, k := range []string{"user:1", "user:2", "item:1", "item:2"} {
+		gc.Get(k)
+	}
+	// user:1 和 user:2 被淘汰到磁盘缓存，仍然可以按标签删除
+	gc.spills.flush()
+	if store.Len() != 2 {
+		t.Fatalf("disk len = %d", store.Len())
+	}
+	if err := gc.InvalidateTag("user"); err != nil {
+		t.Fatal(err)
+	}
+	if store.Len() != 0 {
+		t.Fatal("tagged entries should be removed from disk")
+	}
+	if len(peer.reqs) != 1 || peer.reqs[0].Tag != "user" {
+		t.Fatalf("unexpected requests: %v", peer.reqs)
+	}
+}
+
+func TestGroup_SnapshotTags(t *testing.T) {
+	src := NewRegistry().NewGroup("tags-snapshot", 2<<10, tagGetter)
+	src.Get("user:1")
+	var buf bytes.Buffer
+	if err := src.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	dst := NewRegistry().NewGroup("tags-snapshot", 2<<10, tagGetter)
+	if _, err := dst.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if keys := dst.tags.keysOf("user"); len(keys) != 1 || keys[0] != "user:1" {
+		t.Fatalf("keys of tag user = %v", keys)
+	}
+}
+
+// batchTagGetter 同时实现了 BatchGetter 和 TagGetter，calls 为 GetMany 的调用次数
+type batchTagGetter struct {
+	TagGetterFunc
+	calls int
+}
+
+func (g *batchTagGetter) GetMany(keys []string) (map[string][]byte, error) {
+	g.calls++
+	values := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		values[key] = []byte(key)
+	}
+	return values, nil
+}
+
+// bulkTagGetter 还实现了 BatchTagGetter
+type bulkTagGetter struct {
+	batchTagGetter
+}
+
+func (g *bulkTagGetter) GetManyWithTags(keys []string) (map[string][]byte, map[string][]string, error) {
+	g.calls++
+	values := make(map[string][]byte, len(keys))
+	tags := make(map[string][]string, len(keys))
+	for _, key := range keys {
+		values[key], tags[key], _ = tagGetter(key)
+	}
+	return values, tags, nil
+}
+
+func TestGroup_GetManyTags(t *testing.T) {
+	keys := []string{"user:1", "user:2", "order:1"}
+	check := func(name string, getter Getter, calls *int, wantCalls int) {
+		t.Helper()
+		gc := NewRegistry().NewGroup(name, 2<<10, getter)
+		if values, err := gc.GetMany(keys); err != nil || len(values) != 3 {
+			t.Fatalf("%s: values = %v, err = %v", name, values, err)
+		}
+		if *calls != wantCalls {
+			t.Fatalf("%s: GetMany calls = %d", name, *calls)
+		}
+		// 批量加载的记录同样可以按标签删除
+		gc.InvalidateTag("user")
+		if keys := gc.mainCache.lru.Keys(); len(keys) != 1 || keys[0] != "order:1" {
+			t.Fatalf("%s: keys = %v", name, keys)
+		}
+	}
+	// 只实现 BatchGetter 时逐个调用 GetWithTags
+	g1 := &batchTagGetter{TagGetterFunc: tagGetter}
+	check("tags-get-many", g1, &g1.calls, 0)
+	g2 := &bulkTagGetter{batchTagGetter{TagGetterFunc: tagGetter}}
+	check("tags-get-many-batch", g2, &g2.calls, 1)
+}
+
+// slowTagGetter 加载时等待 release，标签为 key 中冒号之前的部分
+type slowTagGetter struct {
+	*slowGetter
+}
+
+func (s slowTagGetter) GetWithTags(key string) ([]byte, []string, error) {
+	b, err := s.Get(key)
+	return b, []string{strings.SplitN(key, ":", 2)[0]}, err
+}
+
+func TestGroup_InvalidateTagDuringLoad(t *testing.T) {
+	// 加载期间删除其他标签，不影响正在加载的值
+	s := newSlowGetter()
+	gc := NewRegistry().NewGroup("tags-load", 2<<10, slowTagGetter{s})
+	loadDuring(t, gc, s, "user:1", func() {
+		gc.InvalidateTag("order")
+	})
+	if _, ok := gc.mainCache.get("user:1"); !ok || gc.Stats.StaleSets.Get() != 0 {
+		t.Fatal("value with other tags should be cached")
+	}
+
+	// 加载期间删除了值的标签，值不放入缓存
+	s = newSlowGetter()
+	gc = NewRegistry().NewGroup("tags-load", 2<<10, slowTagGetter{s})
+	v := loadDuring(t, gc, s, "user:1", func() {
+		gc.InvalidateTag("user")
+	})
+	if v.String() != "old" {
+		t.Fatalf("v = %q", v)
+	}
+	if _, ok := gc.mainCache.get("user:1"); ok || gc.Stats.StaleSets.Get() != 1 {
+		t.Fatal("value with invalidated tag should not be cached")
+	}
+	if gc.leases.tagVoids != nil {
+		t.Fatal("tag voids should be dropped without leases")
+	}
+}
@@ -0,0 +1,169 @@
+package dcache
+
+import (
+	"dcache/disk"
+	"log"
+	"strings"
+	"sync"
+)
+
+// spill 将因容量被淘汰的记录放入写入队列，由后台写入磁盘缓存，已经过期的记录不写入，返回是否放入队列
+// 在持有缓存的锁时调用，不做磁盘 I/O
+func (g *Group) spill(key string, e entry) bool {
+	expire := g.expireAt(e.fresh)
+	if !expire.IsZero() && !g.now().Before(expire) {
+		return false
+	}
+	g.spills.add(cachedEntry{key: key, entry: e, expire: expire})
+	return true
+}
+
+// deleteFromDisk 从磁盘缓存以及写入队列中删除 key
+func (g *Group) deleteFromDisk(key string) {
+	if g.disk == nil {
+		return
+	}
+	g.spills.cancel(func(k string) bool { return k == key })
+	g.disk.Delete(key)
+}
+
+// deletePrefixFromDisk 从磁盘缓存以及写入队列中删除所有以 prefix 为前缀的key
+func (g *Group) deletePrefixFromDisk(prefix string) {
+	if g.disk == nil {
+		return
+	}
+	g.spills.cancel(func(k string) bool { return strings.HasPrefix(k, prefix) })
+	g.disk.DeletePrefix(prefix)
+}
+
+// spillQueue 等待写入磁盘缓存的记录，由一个后台 goroutine 按淘汰顺序写入，
+// 队列为空时 goroutine 退出，有新的记录时再启动
+type spillQueue struct {
+	store *disk.Store
+
+	mu      sync.Mutex
+	cond    *sync.Cond             // 写入完成或 goroutine 退出时通知
+	pending map[string]cachedEntry // 同一个key 只写入最后一次淘汰的记录
+	keys    []string               // 淘汰顺序，可能包含已经不在 pending 中的key
+	writing string                 // 正在写入的key
+	running bool                   // 后台 goroutine 正在运行
+}
+
+func newSpillQueue(store *disk.Store) *spillQueue {
+	q := &spillQueue{store: store, pending: make(map[string]cachedEntry)}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// add 记录放入队列，需要时启动后台 goroutine
+func (q *spillQueue) add(ce cachedEntry) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.pending[ce.key]; !ok {
+		q.keys = append(q.keys, ce.key)
+	}
+	q.pending[ce.key] = ce
+	if !q.running {
+		q.running = true
+		go q.run()
+	}
+}
+
+func (q *spillQueue) run() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.keys) > 0 {
+		key := q.keys[0]
+		q.keys = q.keys[1:]
+		ce, ok := q.pending[key]
+		if !ok {
+			continue
+		}
+		delete(q.pending, key)
+		q.writing = key
+		q.mu.Unlock()
+		if err := q.store.Put(key, encodeEntry(ce)); err != nil {
+			log.Println("[cache] Failed to write disk tier", key, err)
+		}
+		q.mu.Lock()
+		q.writing = ""
+		q.cond.Broadcast()
+	}
+	q.keys = nil
+	q.running = false
+	q.cond.Broadcast()
+}
+
+// take 从队列中取出还没有写入的记录
+func (q *spillQueue) take(key string) (cachedEntry, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	ce, ok := q.pending[key]
+	delete(q.pending, key)
+	return ce, ok
+}
+
+// cancel 取消写入 match 为 true 的key，正在写入时等待写入完成，返回后不会再写入被取消的记录
+func (q *spillQueue) cancel(match func(key string) bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for key := range q.pending {
+		if match(key) {
+			delete(q.pending, key)
+		}
+	}
+	for q.writing != "" && match(q.writing) {
+		q.cond.Wait()
+	}
+}
+
+// flush 等待队列中所有的记录写入完成
+func (q *spillQueue) flush() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for q.running {
+		q.cond.Wait()
+	}
+}
+
+// getFromDisk 从磁盘缓存中读取新鲜的记录，并放回本地缓存
+// 记录从磁盘缓存中删除，之后再被淘汰时重新写入，避免两层缓存中的值不一致
+func (g *Group) getFromDisk(key string) (entry, bool) {
+	if g.disk == nil {
+		return entry{}, false
+	}
+	// 还没有写入磁盘的记录直接从队列中取出
+	ce, ok := g.spills.take(key)
+	if !ok {
+		b, err := g.disk.Get(key)
+		if err != nil {
+			if err != disk.ErrNotFound {
+				log.Println("[cache] Failed to read disk tier", key, err)
+			}
+			return entry{}, false
+		}
+		g.disk.Delete(key)
+		if ce, err = g.decodeEntry(b, snapshotVersion); err != nil || ce.key != key {
+			log.Println("[cache] Bad disk tier entry", key, err)
+			return entry{}, false
+		}
+	}
+	// 重新放入本地缓存时再设置标签
+	g.tags.remove(key)
+
+	// 不新鲜的记录以及旧代的记录需要重新加载
+	if ce.entry.gen != g.Generation() || !ce.entry.fresh.IsZero() && !g.now().Before(ce.entry.fresh) {
+		return entry{}, false
+	}
+	if err := g.verify(ce.entry); err != nil {
+		log.Println("[cache] Bad disk tier entry", key, err)
+		return entry{}, false
+	}
+	g.Stats.DiskHits.Add(1)
+	g.mainCache.addWithExpire(key, ce.entry, ce.expire)
+	g.tags.set(key, ce.entry.tags)
+	if g.registry != nil {
+		g.registry.enforceBudget()
+	}
+	return ce.entry, true
+}
@@ -0,0 +1,84 @@
+package dcache
+
+import (
+	"dcache/disk"
+	"testing"
+	"time"
+)
+
+func TestGroup_DiskTier(t *testing.T) {
+	store, err := disk.Open(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	loads := make(map[string]int)
+	gc := NewRegistry().NewGroup("tier", 2*(4+entryOverhead), GetterFunc(func(key string) ([]byte, error) {
+		loads[key]++
+		return []byte("v" + key[1:]), nil
+	}), withClock(clock.Now), WithTTL(time.Minute), WithDiskTier(store))
+
+	for _, k := range []string{"k1", "k2", "k3"} {
+		gc.Get(k)
+	}
+	// k1 被淘汰后在后台写入磁盘缓存
+	gc.spills.flush()
+	if _, ok := gc.mainCache.get("k1"); ok || store.Len() != 1 {
+		t.Fatalf("k1 should be spilled to disk, disk len = %d", store.Len())
+	}
+	if v, err := gc.Get("k1"); err != nil || v.String() != "v1" || loads["k1"] != 1 {
+		t.Fatalf("get k1 = %q, %v, loads = %d", v.String(), err, loads["k1"])
+	}
+	if gc.Stats.DiskHits.Get() != 1 {
+		t.Fatalf("disk hits = %d", gc.Stats.DiskHits.Get())
+	}
+	// 放回本地缓存后淘汰了 k2
+	gc.spills.flush()
+	if _, ok := gc.mainCache.get("k1"); !ok || store.Len() != 1 {
+		t.Fatal("k1 should be promoted")
+	}
+
+	// 批量获取同样先查询磁盘缓存
+	values, err := gc.GetMany([]string{"k2"})
+	if err != nil || values["k2"].String() != "v2" || loads["k2"] != 1 {
+		t.Fatalf("get many k2 = %v, %v", values, err)
+	}
+
+	// 不新鲜的记录重新加载
+	clock.Advance(2 * time.Minute)
+	if v, err := gc.Get("k3"); err != nil || v.String() != "v3" || loads["k3"] != 2 {
+		t.Fatalf("get k3 = %q, %v, loads = %d", v.String(), err, loads["k3"])
+	}
+}
+
+func TestGroup_DiskTierQueue(t *testing.T) {
+	store, err := disk.Open(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	gc := NewRegistry().NewGroup("tier-queue", 2*(4+entryOverhead), GetterFunc(func(key string) ([]byte, error) {
+		return []byte("v" + key[1:]), nil
+	}), WithDiskTier(store))
+
+	// 淘汰时只放入队列，由后台写入磁盘，写入前后都可以取回
+	for _, k := range []string{"k1", "k2", "k3", "k4"} {
+		gc.Get(k)
+	}
+	if v, err := gc.Get("k1"); err != nil || v.String() != "v1" || gc.Stats.DiskHits.Get() != 1 {
+		t.Fatalf("get k1 = %q, %v, disk hits = %d", v.String(), err, gc.Stats.DiskHits.Get())
+	}
+	// 删除后不会再写入队列中的记录
+	gc.spills.add(cachedEntry{key: "k9", entry: entry{value: ByteView{b: []byte("v9")}}})
+	gc.Invalidate("k9")
+	gc.Invalidate("k2")
+	gc.spills.flush()
+	if _, err := store.Get("k2"); err != disk.ErrNotFound {
+		t.Fatal("invalidated entry should not be spilled")
+	}
+	if _, err := store.Get("k9"); err != disk.ErrNotFound {
+		t.Fatal("invalidated entry should not be spilled")
+	}
+}
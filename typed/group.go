@@ -0,0 +1,138 @@
+// Package typed 在 dcache.Group 之上提供类型化的缓存，
+// Getter 直接返回 T，缓存和节点间传输的是通过 Codec 编码后的字节
+package typed
+
+import (
+	"dcache"
+	"dcache/lru"
+	"sync"
+)
+
+// Getter 获取类型为 T 的源数据
+type Getter[T any] interface {
+	Get(key string) (T, error)
+}
+
+// GetterFunc 实现了 Getter
+type GetterFunc[T any] func(key string) (T, error)
+
+func (f GetterFunc[T]) Get(key string) (T, error) {
+	return f(key)
+}
+
+// Group 类型化的 dcache.Group
+type Group[T any] struct {
+	group *dcache.Group
+	codec Codec[T]
+	memo  *memo[T] // 解码后的值，未开启时为 nil
+}
+
+// Option 创建 Group 时的可选配置
+type Option func(o *options)
+
+type options struct {
+	memoEntries  int
+	groupOptions []dcache.GroupOption
+	registry     *dcache.Registry
+}
+
+// WithMemo 在本机缓存最多 maxEntries 个解码后的值，命中时不再重复解码
+// 调用方拿到的是同一个值，不能修改
+func WithMemo(maxEntries int) Option {
+	return func(o *options) {
+		o.memoEntries = maxEntries
+	}
+}
+
+// WithGroupOptions 创建底层 dcache.Group 时使用的配置
+func WithGroupOptions(opts ...dcache.GroupOption) Option {
+	return func(o *options) {
+		o.groupOptions = append(o.groupOptions, opts...)
+	}
+}
+
+// WithRegistry 底层的 dcache.Group 放入 r 中，默认为 dcache.DefaultRegistry
+func WithRegistry(r *dcache.Registry) Option {
+	return func(o *options) {
+		o.registry = r
+	}
+}
+
+// NewGroup 创建一个类型化的 Group，底层的 dcache.Group 同样放入 Registry 中
+func NewGroup[T any](name string, cacheBytes int64, codec Codec[T], getter Getter[T], opts ...Option) *Group[T] {
+	if getter == nil {
+		panic("nil getter")
+	}
+	o := options{registry: dcache.DefaultRegistry}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	g := &Group[T]{codec: codec}
+	g.group = o.registry.NewGroup(name, cacheBytes, dcache.GetterFunc(func(key string) ([]byte, error) {
+		v, err := getter.Get(key)
+		if err != nil {
+			return nil, err
+		}
+		return codec.Marshal(v)
+	}), o.groupOptions...)
+	if o.memoEntries > 0 {
+		g.memo = &memo[T]{lru: lru.NewCache[string, memoEntry[T]](int64(o.memoEntries), nil, nil)}
+	}
+	return g
+}
+
+// Group 返回底层的 dcache.Group，可以用于注册节点等
+func (g *Group[T]) Group() *dcache.Group {
+	return g.group
+}
+
+// Get 获取 key 对应的值
+func (g *Group[T]) Get(key string) (T, error) {
+	view, err := g.group.Get(key)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	if g.memo != nil {
+		if v, ok := g.memo.get(key, view); ok {
+			return v, nil
+		}
+	}
+
+	v, err := g.codec.Unmarshal(view.ByteSlice())
+	if err != nil {
+		return v, err
+	}
+	if g.memo != nil {
+		g.memo.add(key, view, v)
+	}
+	return v, nil
+}
+
+type memoEntry[T any] struct {
+	view  dcache.ByteView // 解码前的值，用于判断缓存的值是否已经变化
+	value T
+}
+
+// memo 保存解码后的值
+type memo[T any] struct {
+	mu  sync.Mutex
+	lru *lru.Cache[string, memoEntry[T]]
+}
+
+func (m *memo[T]) get(key string, view dcache.ByteView) (v T, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.lru.Get(key)
+	if !ok || !e.view.Equal(view) {
+		return v, false
+	}
+	return e.value, true
+}
+
+func (m *memo[T]) add(key string, view dcache.ByteView, v T) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lru.Add(key, memoEntry[T]{view: view, value: v})
+}
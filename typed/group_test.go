@@ -0,0 +1,78 @@
+package typed
+
+import (
+	"dcache"
+	"dcache/cachepb"
+	"fmt"
+	"testing"
+)
+
+type user struct {
+	Name  string
+	Score int
+}
+
+var db = map[string]user{
+	"Tom":  {Name: "Tom", Score: 630},
+	"Jack": {Name: "Jack", Score: 589},
+}
+
+func userGetter(loads *int) Getter[user] {
+	return GetterFunc[user](func(key string) (user, error) {
+		*loads++
+		if u, ok := db[key]; ok {
+			return u, nil
+		}
+		return user{}, fmt.Errorf("%s: %w", key, dcache.ErrNotFound)
+	})
+}
+
+func TestGroup_Get(t *testing.T) {
+	for name, codec := range map[string]Codec[user]{
+		"json": JSONCodec[user]{},
+		"gob":  GobCodec[user]{},
+	} {
+		loads := 0
+		g := NewGroup[user]("typed-"+name, 2<<10, codec, userGetter(&loads))
+		for i := 0; i < 2; i++ {
+			u, err := g.Get("Tom")
+			if err != nil || u != db["Tom"] {
+				t.Fatalf("%s: got %v, %v", name, u, err)
+			}
+		}
+		if loads != 1 {
+			t.Fatalf("%s: loads = %d, want 1", name, loads)
+		}
+		if _, err := g.Get("Nobody"); !dcache.IsNotFound(err) {
+			t.Fatalf("%s: err = %v, want not found", name, err)
+		}
+	}
+}
+
+func TestGroup_Proto(t *testing.T) {
+	g := NewGroup[*cachepb.Request]("typed-proto", 2<<10, ProtoCodec[*cachepb.Request]{},
+		GetterFunc[*cachepb.Request](func(key string) (*cachepb.Request, error) {
+			return &cachepb.Request{Group: "g", Key: key}, nil
+		}))
+	req, err := g.Get("k")
+	if err != nil || req.Group != "g" || req.Key != "k" {
+		t.Fatalf("got %v, %v", req, err)
+	}
+}
+
+func TestGroup_Memo(t *testing.T) {
+	loads := 0
+	g := NewGroup[*user]("typed-memo", 2<<10, JSONCodec[*user]{},
+		GetterFunc[*user](func(key string) (*user, error) {
+			loads++
+			u := db[key]
+			return &u, nil
+		}), WithMemo(10))
+
+	u1, _ := g.Get("Jack")
+	u2, _ := g.Get("Jack")
+	// 命中 memo 时返回同一个解码后的值
+	if u1 != u2 || u1.Score != 589 {
+		t.Fatal("memoized value should be reused")
+	}
+}